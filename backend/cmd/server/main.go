@@ -1,33 +1,95 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"voicebook/internal/app"
+	"voicebook/internal/config"
+	"voicebook/internal/logging"
 )
 
 func main() {
-	logger := slog.Default()
-	a, err := app.New(logger)
+	os.Exit(run())
+}
+
+func run() int {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file")
+	printConfig := flag.Bool("print-config", false, "print effective config with secrets redacted and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	// неверную конфигурацию сначала печатаем: по ней и ищут, откуда взялось значение
+	if *printConfig && cfg != nil {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
 	if err != nil {
-		log.Fatalf("failed to init app: %v", err)
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 2
+	}
+	if *printConfig {
+		return 0
 	}
 
-	host := getenv("HOST", "0.0.0.0")
-	port := getenv("PORT", "8080")
-	addr := fmt.Sprintf("%s:%s", host, port)
-	log.Printf("Server started on %s", addr)
-	if err := http.ListenAndServe(addr, a.Router); err != nil {
-		log.Fatalf("server stopped: %v", err)
+	logger := logging.New(cfg.Log.Level, cfg.Log.Format)
+	slog.SetDefault(logger)
+
+	a, err := app.New(cfg, logger)
+	if err != nil {
+		logger.Error("failed to init app", "error", err)
+		return 1
 	}
-}
 
-func getenv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+	addr := cfg.Server.Addr()
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           a.Router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
 	}
-	return fallback
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("server started", "addr", addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	code := 0
+	select {
+	case err := <-serveErr:
+		logger.Error("server stopped", "error", err)
+		code = 1
+	case <-ctx.Done():
+		logger.Info("shutdown signal received, draining")
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("http shutdown failed", "error", err)
+		code = 1
+	}
+	if err := a.Shutdown(shutdownCtx); err != nil {
+		logger.Error("app shutdown failed", "error", err)
+		code = 1
+	}
+
+	logger.Info("server exited", "code", code)
+	return code
 }
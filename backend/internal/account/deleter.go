@@ -0,0 +1,150 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"voicebook/internal/logging"
+	"voicebook/internal/s3client"
+	"voicebook/internal/storage"
+	"voicebook/internal/tracing"
+)
+
+// Deleter удаляет аккаунты в фоне. Каждый шаг можно повторить, поэтому прерванное
+// остановкой сервера или упавшее удаление просто запускается заново.
+type Deleter struct {
+	st     *storage.Storage
+	cl     *s3client.Client
+	logger *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// одно удаление за раз: спешить некуда, доступ к аккаунту уже закрыт
+	sem chan struct{}
+}
+
+func NewDeleter(st *storage.Storage, cl *s3client.Client, logger *slog.Logger) *Deleter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Deleter{
+		st:     st,
+		cl:     cl,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+		sem:    make(chan struct{}, 1),
+	}
+}
+
+// Resume перезапускает удаления, которые не дошли до конца
+func (d *Deleter) Resume() error {
+	jobs, err := d.st.GetUnfinishedAccountDeletions(d.ctx)
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		d.Enqueue(j.Login)
+	}
+	return nil
+}
+
+func (d *Deleter) Enqueue(login string) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		select {
+		case d.sem <- struct{}{}:
+		case <-d.ctx.Done():
+			return
+		}
+		defer func() { <-d.sem }()
+
+		d.run(login)
+	}()
+}
+
+// Shutdown ждёт завершения удалений; по истечении ctx прерывает их,
+// и они продолжатся при следующем запуске
+func (d *Deleter) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (d *Deleter) run(login string) {
+	logger := d.logger.With("deleted_login", login)
+	logger.Info("account deletion started")
+
+	ctx, span := tracing.Tracer().Start(d.ctx, "account.delete", trace.WithAttributes(
+		attribute.String("account.login", login),
+	))
+	defer span.End()
+	ctx = logging.WithLogger(ctx, logger)
+	stCtx := context.WithoutCancel(ctx)
+
+	if err := d.st.UpdateAccountDeletion(stCtx, login, storage.DeletionRunning, ""); err != nil {
+		logger.Error("account deletion status update failed", "error", err)
+		return
+	}
+
+	err := d.delete(ctx, login)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Info("account deletion interrupted")
+		err = d.st.UpdateAccountDeletion(stCtx, login, storage.DeletionPending, "")
+	case err != nil:
+		logger.Error("account deletion failed", "error", err)
+		err = d.st.UpdateAccountDeletion(stCtx, login, storage.DeletionFailed, err.Error())
+	default:
+		logger.Info("account deleted")
+	}
+	if err != nil {
+		logger.Error("account deletion status update failed", "error", err)
+	}
+}
+
+// delete сначала убирает объекты из S3, а строки из базы — последним шагом:
+// пока строки на месте, по ним можно найти, что ещё осталось удалить
+func (d *Deleter) delete(ctx context.Context, login string) error {
+	urls, err := d.st.GetUserObjectURLs(ctx, login)
+	if err != nil {
+		return fmt.Errorf("get user objects: %w", err)
+	}
+	for _, u := range urls {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := d.cl.DeleteObject(ctx, u); err != nil {
+			return fmt.Errorf("delete object: %w", err)
+		}
+	}
+
+	if err := d.st.DeleteUserData(ctx, login); err != nil {
+		return fmt.Errorf("delete user data: %w", err)
+	}
+	return nil
+}
@@ -1,34 +1,54 @@
 package app
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
-	"strconv"
 
 	_ "github.com/lib/pq"
 
+	"voicebook/internal/account"
+	"voicebook/internal/config"
+	"voicebook/internal/export"
 	"voicebook/internal/handler"
-	"voicebook/internal/storage"
+	"voicebook/internal/health"
+	"voicebook/internal/mailer"
+	"voicebook/internal/metrics"
+	"voicebook/internal/oidc"
+	"voicebook/internal/ratelimit"
 	"voicebook/internal/s3client"
+	"voicebook/internal/storage"
+	"voicebook/internal/token"
+	"voicebook/internal/tracing"
+	"voicebook/internal/transcode"
+	"voicebook/internal/tts"
+
+	"github.com/XSAM/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type App struct {
-	Router http.Handler
-	DB     *sql.DB
-	Client *s3client.Client
+	Router  http.Handler
+	DB      *sql.DB
+	Client  *s3client.Client
+	Exports *export.Service
+	// Deletions удаляет аккаунты в фоне
+	Deletions *account.Deleter
+
+	shutdownTracing func(context.Context) error
 }
 
-func New(logger *slog.Logger) (*App, error) {
-	if os.Getenv("DATABASE_URL") == "" {
-		cfg := postgresConfigFromEnv()
-		connRoot := fmt.Sprintf(
-			"host=%s port=%d user=%s password=%s dbname=postgres sslmode=%s",
-			cfg.host, cfg.port, cfg.user, cfg.password, cfg.sslmode,
-		)
-		dbRoot, err := sql.Open("postgres", connRoot)
+func New(cfg *config.Config, logger *slog.Logger) (*App, error) {
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing.Exporter)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Database.URL == "" {
+		dbRoot, err := sql.Open("postgres", cfg.Database.DSN("postgres"))
 		if err != nil {
 			return nil, err
 		}
@@ -38,14 +58,16 @@ func New(logger *slog.Logger) (*App, error) {
 			return nil, err
 		}
 
-		_, err = dbRoot.Exec("CREATE DATABASE " + cfg.dbname)
+		_, err = dbRoot.Exec("CREATE DATABASE " + cfg.Database.Name)
 		if err != nil && !containsAlreadyExists(err) {
 			return nil, err
 		}
 	}
 
-	conn := postgresConnString()
-	db, err := sql.Open("postgres", conn)
+	db, err := otelsql.Open("postgres", cfg.Database.ConnString(),
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitRows: true, OmitConnResetSession: true}),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -54,77 +76,137 @@ func New(logger *slog.Logger) (*App, error) {
 		return nil, err
 	}
 
+	metrics.RegisterDB(db)
+
 	st := storage.New(db)
 	if err := st.InitDB(); err != nil {
 		return nil, fmt.Errorf("failed to init database: %w", err)
 	}
 
-	cl, err := s3client.New()
+	if err := st.PromoteAdmins(context.Background(), cfg.Admin.Logins); err != nil {
+		return nil, fmt.Errorf("failed to promote admins: %w", err)
+	}
+
+	cl, err := s3client.New(cfg.S3)
 	if err != nil {
 		return nil, err
 	}
 
-	h := handler.New(st, cl)
+	tc := tts.New(cfg.TTS.URL, cfg.TTS.SSML)
 
-	app := &App{
-		Router: NewRouter(h, logger),
-		DB:     db,
-		Client: cl,
+	enc, err := transcode.NewFFmpeg()
+	if err != nil {
+		logger.Warn("audio transcoding disabled, formats will be synthesized by TTS", "error", err)
 	}
-	
 
-	return app, nil
-}
+	quota := storage.Quota{
+		MaxBytes:     cfg.Quota.MaxBytes,
+		MaxBooks:     cfg.Quota.MaxBooks,
+		MonthlyChars: cfg.Quota.MonthlyChars,
+	}
+	ex := export.New(st, cl, tc, enc, quota, logger)
+	if err := ex.Resume(); err != nil {
+		return nil, fmt.Errorf("failed to resume exports: %w", err)
+	}
 
-func postgresConnString() string {
-	if v := os.Getenv("DATABASE_URL"); v != "" {
-		return v
+	del := account.NewDeleter(st, cl, logger)
+	if err := del.Resume(); err != nil {
+		return nil, fmt.Errorf("failed to resume account deletions: %w", err)
 	}
 
-	cfg := postgresConfigFromEnv()
-	return fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.host, cfg.port, cfg.user, cfg.password, cfg.dbname, cfg.sslmode,
-	)
-}
+	tokens, err := newTokenIssuer(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init token auth: %w", err)
+	}
 
-type postgresConfig struct {
-	host     string
-	port     int
-	user     string
-	password string
-	dbname   string
-	sslmode  string
-}
+	mail, err := mailer.New(cfg.Mail, logger)
+	if err != nil {
+		return nil, err
+	}
 
-func postgresConfigFromEnv() postgresConfig {
-	return postgresConfig{
-		host:     getenv("POSTGRES_HOST", "localhost"),
-		port:     getenvInt("POSTGRES_PORT", 5432),
-		user:     getenv("POSTGRES_USER", "postgres"),
-		password: os.Getenv("POSTGRES_PASSWORD"),
-		dbname:   getenv("POSTGRES_DB", "voicebook"),
-		sslmode:  getenv("POSTGRES_SSLMODE", "disable"),
+	auth := handler.Auth{
+		Tokens:       tokens,
+		RefreshTTL:   cfg.Auth.RefreshTTL,
+		PostLoginURL: cfg.OIDC.PostLoginURL,
+		Mailer:       mail,
+		PublicURL:    cfg.Account.PublicURL,
+		VerifyTTL:    cfg.Account.VerifyTTL,
+		ResetTTL:     cfg.Account.ResetTTL,
+	}
+	if cfg.OIDC.Enabled {
+		auth.OIDC, err = oidc.New(context.Background(), cfg.OIDC)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	h := handler.New(st, cl, tc, ex, del, enc, auth, quota, cfg.Library.Enabled)
+
+	hc := health.New(cfg.Health.CacheTTL, cfg.Health.CheckTimeout)
+	hc.Add("database", db.PingContext)
+	hc.Add("storage", cl.Ping)
+	hc.Add("tts", tc.Ping)
+
+	app := &App{
+		Router:          NewRouter(h, hc, newRateLimiter(cfg.RateLimit, db, logger), cfg.CORS, logger),
+		DB:              db,
+		Client:          cl,
+		Exports:         ex,
+		Deletions:       del,
+		shutdownTracing: shutdownTracing,
 	}
+
+	return app, nil
+}
+
+// Shutdown дожидается фоновых задач (не дольше ctx), закрывает соединения с базой
+// и отправляет накопленные спаны
+func (a *App) Shutdown(ctx context.Context) error {
+	return errors.Join(
+		a.Exports.Shutdown(ctx),
+		a.Deletions.Shutdown(ctx),
+		a.DB.Close(),
+		a.shutdownTracing(ctx),
+	)
 }
 
-func getenv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// newTokenIssuer возвращает nil, если вход по access-токенам выключен
+func newTokenIssuer(c config.Auth) (*token.Issuer, error) {
+	if !c.Tokens {
+		return nil, nil
 	}
-	return fallback
+	keys := make([]token.Key, 0, len(c.Keys))
+	for _, s := range c.Keys {
+		k, err := token.ParseKey(s)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return token.NewIssuer(c.Issuer, c.AccessTTL, keys)
 }
 
-func getenvInt(key string, fallback int) int {
-	v := os.Getenv(key)
-	if v == "" {
-		return fallback
+// newRateLimiter держит лимиты в памяти, а при нескольких репликах — в базе (store: postgres)
+func newRateLimiter(c config.RateLimit, db *sql.DB, logger *slog.Logger) *ratelimit.Guard {
+	cfg := ratelimit.Config{
+		PerIP:    ratelimit.Rule{PerMinute: c.IPPerMinute, Burst: c.IPBurst},
+		PerLogin: ratelimit.Rule{PerMinute: c.LoginPerMinute, Burst: c.LoginBurst},
+		Backoff: ratelimit.Backoff{
+			Threshold: c.LockoutThreshold,
+			Base:      c.LockoutBase,
+			Max:       c.LockoutMax,
+		},
+		TrustProxy:  c.TrustProxy,
+		TrustedHops: c.TrustedHops,
 	}
-	n, err := strconv.Atoi(v)
-	if err != nil {
-		return fallback
+
+	if c.Store == "postgres" {
+		pg := ratelimit.NewPostgres(db)
+		return ratelimit.New(pg, pg, cfg, handler.TooManyRequests)
 	}
-	return n
+	logger.Debug("rate limits are kept in memory, they are not shared between replicas")
+	mem := ratelimit.NewMemory()
+	return ratelimit.New(mem, mem, cfg, handler.TooManyRequests)
 }
 
 // helper для ошибки "database already exists"
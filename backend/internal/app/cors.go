@@ -0,0 +1,73 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"voicebook/internal/config"
+)
+
+// corsMiddleware отвечает на preflight сам и добавляет заголовки CORS к ответам
+// для разрешённых источников; остальные запросы проходят без заголовков
+func corsMiddleware(cfg config.CORS) func(http.Handler) http.Handler {
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin != "" {
+				// ответ зависит от Origin, кэши не должны отдавать его другому источнику
+				w.Header().Add("Vary", "Origin")
+			}
+			allowed := origin != "" && originAllowed(cfg.AllowedOrigins, origin)
+
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				if cfg.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+				if exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposed)
+				}
+			}
+
+			// Обработка preflight запроса (OPTIONS)
+			if r.Method == http.MethodOptions {
+				if allowed && r.Header.Get("Access-Control-Request-Method") != "" {
+					w.Header().Add("Vary", "Access-Control-Request-Method")
+					w.Header().Add("Vary", "Access-Control-Request-Headers")
+					w.Header().Set("Access-Control-Allow-Methods", methods)
+					w.Header().Set("Access-Control-Allow-Headers", headers)
+					if cfg.MaxAge > 0 {
+						w.Header().Set("Access-Control-Max-Age", maxAge)
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func originAllowed(allowed []string, origin string) bool {
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(a, origin) {
+			return true
+		}
+		// "https://*.example.com" пропускает поддомены любой глубины, но не сам example.com
+		if prefix, suffix, ok := strings.Cut(a, "*"); ok {
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+				strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+				return true
+			}
+		}
+	}
+	return false
+}
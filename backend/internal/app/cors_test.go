@@ -0,0 +1,96 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"voicebook/internal/config"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://app.example.com", "https://*.example.org", "http://localhost:5173"}
+	for origin, want := range map[string]bool{
+		"https://app.example.com":    true,
+		"HTTPS://APP.EXAMPLE.COM":    true,
+		"https://evil.example.com":   false,
+		"https://a.example.org":      true,
+		"https://a.b.example.org":    true,
+		"https://example.org":        false,
+		"https://.example.org":       false,
+		"http://a.example.org":       false,
+		"https://a.example.org.evil": false,
+		"https://evilexample.org":    false,
+		"http://localhost:5173":      true,
+		"http://localhost:8080":      false,
+	} {
+		if got := originAllowed(allowed, origin); got != want {
+			t.Errorf("originAllowed(%q) = %v, want %v", origin, got, want)
+		}
+	}
+	if !originAllowed([]string{"*"}, "https://anything.test") {
+		t.Error(`"*" must allow any origin`)
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	cfg := config.CORS{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	var reached bool
+	h := corsMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	for _, tc := range []struct {
+		name, method, origin, requestMethod string
+		wantOrigin, wantMethods             string
+		wantCredentials                     bool
+		wantReached                         bool
+	}{
+		{"allowed request", http.MethodGet, "https://app.example.com", "", "https://app.example.com", "", true, true},
+		{"foreign request", http.MethodGet, "https://evil.test", "", "", "", false, true},
+		{"no origin", http.MethodGet, "", "", "", "", false, true},
+		{"preflight", http.MethodOptions, "https://app.example.com", "POST", "https://app.example.com", "GET, POST", true, false},
+		{"foreign preflight", http.MethodOptions, "https://evil.test", "POST", "", "", false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reached = false
+			r := httptest.NewRequest(tc.method, "/api/", nil)
+			if tc.origin != "" {
+				r.Header.Set("Origin", tc.origin)
+			}
+			if tc.requestMethod != "" {
+				r.Header.Set("Access-Control-Request-Method", tc.requestMethod)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			hdr := w.Header()
+			if got := hdr.Get("Access-Control-Allow-Origin"); got != tc.wantOrigin {
+				t.Errorf("Allow-Origin = %q, want %q", got, tc.wantOrigin)
+			}
+			if got := hdr.Get("Access-Control-Allow-Methods"); got != tc.wantMethods {
+				t.Errorf("Allow-Methods = %q, want %q", got, tc.wantMethods)
+			}
+			if got := hdr.Get("Access-Control-Allow-Credentials") == "true"; got != tc.wantCredentials {
+				t.Errorf("Allow-Credentials = %v, want %v", got, tc.wantCredentials)
+			}
+			if tc.origin != "" && hdr.Get("Vary") == "" {
+				t.Error("response to a request with Origin must vary on it")
+			}
+			if reached != tc.wantReached {
+				t.Errorf("handler reached = %v, want %v", reached, tc.wantReached)
+			}
+			if tc.method == http.MethodOptions && w.Code != http.StatusNoContent {
+				t.Errorf("preflight status %d, want 204", w.Code)
+			}
+		})
+	}
+}
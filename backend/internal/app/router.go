@@ -3,68 +3,179 @@ package app
 import (
 	"log/slog"
 	"net/http"
+	"regexp"
 	"time"
 
+	"voicebook/internal/config"
 	"voicebook/internal/handler"
+	"voicebook/internal/health"
+	"voicebook/internal/logging"
+	"voicebook/internal/metrics"
+	"voicebook/internal/ratelimit"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func NewRouter(h *handler.Handler, logger *slog.Logger) http.Handler {
+// пробы оркестратора приходят каждые несколько секунд и только засоряют лог
+var unloggedPaths = map[string]bool{
+	"/healthz": true, "/healthz/": true,
+	"/readyz": true, "/readyz/": true,
+	"/metrics": true, "/metrics/": true,
+}
+
+// токен фида — секрет, открывающий книги пользователя: в лог путь попадает без него
+var feedTokenRe = regexp.MustCompile(`^/feeds/[^/]+`)
+
+func logPath(path string) string {
+	return feedTokenRe.ReplaceAllString(path, "/feeds/{token}")
+}
+
+// validRequestID отсекает чужие id, которые испортили бы строку лога
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func NewRouter(h *handler.Handler, hc *health.Checker, rl *ratelimit.Guard, cors config.CORS, logger *slog.Logger) http.Handler {
 	r := chi.NewRouter()
+	// спан на каждый запрос; когда chi найдёт маршрут, otelhttp переименует спан.
+	// r.Pattern у вложенных роутеров относительный, поэтому полный шаблон берём из RouteContext
+	r.Use(otelhttp.NewMiddleware("http.request",
+		otelhttp.WithFilter(func(r *http.Request) bool { return !unloggedPaths[r.URL.Path] }),
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			rctx := chi.RouteContext(r.Context())
+			if rctx == nil || rctx.RoutePattern() == "" {
+				return operation
+			}
+			return r.Method + " " + rctx.RoutePattern()
+		}),
+	))
+	// X-Request-Id: берём от прокси или клиента, иначе генерируем
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get("X-Request-Id")
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set("X-Request-Id", id)
+
+			reqLogger := logger.With("request_id", id)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				reqLogger = reqLogger.With("trace_id", sc.TraceID().String())
+			}
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("http.request_id", id))
+
+			ctx := logging.WithLogger(r.Context(), reqLogger)
+			ctx = logging.WithRequest(ctx, &logging.Request{ID: id})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+
 	// Logging middleware — можно оставлять здесь (до Route)
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if unloggedPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			reqLogger := logging.FromContext(r.Context())
 			start := time.Now()
-			logger.Info("HTTP request started",
+			reqLogger.Debug("HTTP request started",
 				"method", r.Method,
-				"path", r.URL.Path,
+				"path", logPath(r.URL.Path),
 				"remote_addr", r.RemoteAddr,
 			)
 
-			next.ServeHTTP(w, r)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
 
-			logger.Info("HTTP request finished",
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			switch {
+			case status >= 500:
+				level = slog.LevelError
+			case status >= 400:
+				level = slog.LevelWarn
+			}
+			attrs := []any{
 				"method", r.Method,
-				"path", r.URL.Path,
+				"path", logPath(r.URL.Path),
+				"status", status,
+				"bytes", ww.BytesWritten(),
 				"duration_ms", time.Since(start).Milliseconds(),
-			)
+			}
+			if req := logging.RequestFromContext(r.Context()); req != nil && req.Login != "" {
+				attrs = append(attrs, "login", req.Login)
+			}
+			reqLogger.Log(r.Context(), level, "HTTP request finished", attrs...)
 		})
 	})
 
+	// метрики по шаблону маршрута chi, а не по пути: иначе id книг раздувают число серий
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			path := r.URL.Path
-			if path[len(path)-1] != '/' {
-				r.URL.Path = path + "/"
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			route := chi.RouteContext(r.Context()).RoutePattern()
+			if route == "" {
+				route = "unmatched"
 			}
-			next.ServeHTTP(w, r)
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			metrics.ObserveHTTP(r.Method, route, status, time.Since(start))
 		})
 	})
 
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			origin := r.Header.Get("Origin")
-			if origin == "http://127.0.0.1:5173" || origin == "http://localhost:5173" || origin == "http://158.160.73.166"{
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Session-Id")
-			}
-
-			// Обработка preflight запроса (OPTIONS)
-			if r.Method == http.MethodOptions {
-				w.WriteHeader(http.StatusOK)
-				return
+			path := r.URL.Path
+			if path[len(path)-1] != '/' {
+				r.URL.Path = path + "/"
 			}
-
 			next.ServeHTTP(w, r)
 		})
 	})
 
+	r.Use(corsMiddleware(cors))
+
+	r.NotFound(handler.NotFound)
+	r.MethodNotAllowed(handler.MethodNotAllowed)
+
+	// health-пробы без авторизации
+	r.Get("/healthz/", health.Live)
+	r.Get("/readyz/", hc.Ready)
+	r.Method(http.MethodGet, "/metrics/", metrics.Handler())
+
 	r.Route("/api", func(r chi.Router) {
-		// public endp
-		r.Post("/register/", h.Register)
-		r.Post("/login/", h.Login)
+		// public endp; подбор паролей и массовая регистрация упираются в лимиты
+		r.With(rl.PerIP("register")).Post("/register/", h.Register)
+		r.With(rl.PerIP("login"), rl.PerLogin("login"), rl.Lockout).Post("/login/", h.Login)
+		r.With(rl.PerIP("refresh")).Post("/token/refresh/", h.RefreshToken)
+		r.With(rl.PerIP("oidc")).Get("/oidc/login/", h.OIDCLogin)
+		r.With(rl.PerIP("oidc")).Get("/oidc/callback/", h.OIDCCallback)
+		r.With(rl.PerIP("email")).Post("/email/verify/", h.VerifyEmail)
+		r.With(rl.PerIP("reset"), rl.PerLogin("reset")).Post("/password/reset/", h.RequestPasswordReset)
+		r.With(rl.PerIP("reset")).Post("/password/reset/confirm/", h.ResetPassword)
 
 		// private endp
 		r.Group(func(r chi.Router) {
@@ -74,18 +185,63 @@ func NewRouter(h *handler.Handler, logger *slog.Logger) http.Handler {
 			r.Get("/collection/", h.GetCollection)
 			r.Get("/myself/", h.Myself)
 			r.Post("/logout/", h.Logout)
+			r.Put("/email/", h.PutEmail)
+			r.Get("/me/export/", h.ExportAccount)
+			r.Delete("/me/", h.DeleteAccount)
+			r.Get("/me/usage/", h.GetUsage)
+			r.Post("/shares/accept/", h.AcceptInvite)
+			r.With(h.LibraryMiddleware).Get("/library/", h.GetLibrary)
+			r.With(h.LibraryMiddleware).Post("/library/{bookId}/", h.PostLibraryBook)
 			r.Post("/book/", h.PostBook)
+			r.Get("/settings/", h.GetSettings)
+			r.Put("/settings/", h.PutSettings)
+			r.Get("/export/{exportId}/", h.GetExport)
+			r.Get("/export/{exportId}/download/", h.DownloadExport)
+			r.Delete("/export/{exportId}/", h.DeleteExport)
+			r.Post("/feeds/token/", h.PostFeedToken)
+			r.Delete("/feeds/token/", h.DeleteFeedToken)
+
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(h.AdminMiddleware)
+
+				r.Get("/users/", h.AdminListUsers)
+				r.Get("/users/{login}/", h.AdminGetUser)
+				r.Patch("/users/{login}/", h.AdminPatchUser)
+				r.Delete("/users/{login}/sessions/", h.AdminRevokeSessions)
+				r.Put("/users/{login}/quota/", h.AdminPutQuota)
+				r.Delete("/books/{bookId}/", h.AdminDeleteBook)
+				r.Get("/jobs/", h.AdminJobs)
+				r.With(h.LibraryMiddleware).Post("/library/", h.AdminPublishBook)
+			})
 
 			r.Route("/book/{bookId}", func(r chi.Router) {
 				r.Get("/", h.GetBook)
 				r.Delete("/", h.DeleteBook)
 				r.Get("/currentPage/", h.GetCurrentPage)
+				r.Get("/settings/", h.GetBookSettings)
+				r.Put("/settings/", h.PutBookSettings)
+				r.Get("/chapters/", h.GetChapters)
+				r.Post("/export/", h.PostExport)
+				r.Get("/feed/", h.GetFeedEpisodes)
+				r.Post("/feed/", h.PostFeedEpisodes)
+				r.Get("/shares/", h.GetBookShares)
+				r.Post("/shares/", h.PostBookShare)
+				r.Delete("/shares/{shareId}/", h.DeleteBookShare)
 				r.Get("/page/{pageId}/audio/", h.GetPageAudio)
 				r.Get("/page/{pageId}/text/", h.GetPageText)
+				r.Get("/page/{pageId}/timings/", h.GetPageTimings)
 
 			})
 		})
 	})
 
+	// подкаст-фиды: авторизация по токену в пути
+	r.Route("/feeds/{token}", func(r chi.Router) {
+		r.Use(h.FeedAuthMiddleware)
+
+		r.Get("/book/{bookId}.xml/", h.GetBookFeed)
+		r.Get("/book/{bookId}/chapter/{chapter}.mp3/", h.GetFeedEpisode)
+	})
+
 	return r
 }
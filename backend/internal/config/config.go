@@ -0,0 +1,383 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Config — все настройки сервера. Значения берутся по порядку: значения по умолчанию,
+// файл (YAML), переменные окружения. В теге env можно перечислить несколько имён —
+// побеждает первое непустое; поля с тегом secret скрываются при печати
+// (secret:"url" — только пароль в строке подключения).
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Log       Log       `yaml:"log"`
+	Database  Database  `yaml:"database"`
+	S3        S3        `yaml:"s3"`
+	TTS       TTS       `yaml:"tts"`
+	Auth      Auth      `yaml:"auth"`
+	OIDC      OIDC      `yaml:"oidc"`
+	Account   Account   `yaml:"account"`
+	Admin     Admin     `yaml:"admin"`
+	Quota     Quota     `yaml:"quota"`
+	Library   Library   `yaml:"library"`
+	Mail      Mail      `yaml:"mail"`
+	Tracing   Tracing   `yaml:"tracing"`
+	Health    Health    `yaml:"health"`
+	CORS      CORS      `yaml:"cors"`
+	RateLimit RateLimit `yaml:"rateLimit"`
+}
+
+type Server struct {
+	Host string `yaml:"host" env:"HOST"`
+	Port int    `yaml:"port" env:"PORT"`
+	// синтез и отдача аудио страницы могут занимать до минуты
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"readTimeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+// Addr — адрес для http.Server
+func (s Server) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+type Log struct {
+	// debug, info, warn, error
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+	// text или json
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+}
+
+// Database — либо URL целиком, либо параметры по отдельности. Без URL сервер
+// сам создаёт базу Name, если её ещё нет.
+type Database struct {
+	URL      string `yaml:"url" env:"DATABASE_URL" secret:"url"`
+	Host     string `yaml:"host" env:"POSTGRES_HOST"`
+	Port     int    `yaml:"port" env:"POSTGRES_PORT"`
+	User     string `yaml:"user" env:"POSTGRES_USER"`
+	Password string `yaml:"password" env:"POSTGRES_PASSWORD" secret:"true"`
+	Name     string `yaml:"name" env:"POSTGRES_DB"`
+	SSLMode  string `yaml:"sslmode" env:"POSTGRES_SSLMODE"`
+}
+
+// DSN — строка подключения к базе dbname
+func (d Database) DSN(dbname string) string {
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, d.Password, dbname, d.SSLMode,
+	)
+}
+
+// ConnString — строка подключения к базе приложения
+func (d Database) ConnString() string {
+	if d.URL != "" {
+		return d.URL
+	}
+	return d.DSN(d.Name)
+}
+
+// S3 — хранилище объектов; имена YC_* оставлены для старых окружений
+type S3 struct {
+	AccessKey string `yaml:"accessKey" env:"AWS_ACCESS_KEY_ID,YC_S3_API_KEY"`
+	SecretKey string `yaml:"secretKey" env:"AWS_SECRET_ACCESS_KEY,YC_S3_API_SECRET" secret:"true"`
+	Bucket    string `yaml:"bucket" env:"AWS_BUCKET,S3_BUCKET,YC_S3_BUCKET"`
+	Region    string `yaml:"region" env:"AWS_REGION,AWS_DEFAULT_REGION,S3_REGION,YC_S3_REGION"`
+	Endpoint  string `yaml:"endpoint" env:"AWS_ENDPOINT,S3_ENDPOINT,YC_S3_ENDPOINT"`
+}
+
+type TTS struct {
+	URL string `yaml:"url" env:"TTS_URL"`
+	// SSML — сервис понимает SSML-разметку пауз и ударений
+	SSML bool `yaml:"ssml" env:"TTS_SSML"`
+}
+
+// Auth — вход без обращения к таблице сессий: подписанные access-токены
+// (Authorization: Bearer) и refresh-токены в базе. X-Session-Id работает всегда.
+type Auth struct {
+	Tokens     bool          `yaml:"tokens" env:"AUTH_TOKENS"`
+	Issuer     string        `yaml:"issuer" env:"AUTH_TOKEN_ISSUER"`
+	AccessTTL  time.Duration `yaml:"accessTTL" env:"AUTH_ACCESS_TTL"`
+	RefreshTTL time.Duration `yaml:"refreshTTL" env:"AUTH_REFRESH_TTL"`
+	// Keys — ключи подписи "kid:alg:base64" (alg — HS256 или EdDSA).
+	// Подписывает первый, проверяют все: новый ключ ставится первым, старый
+	// убирается, когда истекут выданные им токены.
+	Keys []string `yaml:"keys" env:"AUTH_TOKEN_KEYS" secret:"true"`
+}
+
+// OIDC — вход через внешнего провайдера (authorization code + PKCE).
+// RedirectURL — адрес /api/oidc/callback/ этого сервера, зарегистрированный у провайдера;
+// PostLoginURL — страница фронтенда, куда после входа уходят sessionId и токены (во фрагменте URL).
+type OIDC struct {
+	Enabled      bool     `yaml:"enabled" env:"OIDC_ENABLED"`
+	Issuer       string   `yaml:"issuer" env:"OIDC_ISSUER"`
+	ClientID     string   `yaml:"clientId" env:"OIDC_CLIENT_ID"`
+	ClientSecret string   `yaml:"clientSecret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	Scopes       []string `yaml:"scopes" env:"OIDC_SCOPES"`
+	RedirectURL  string   `yaml:"redirectUrl" env:"OIDC_REDIRECT_URL"`
+	PostLoginURL string   `yaml:"postLoginUrl" env:"OIDC_POST_LOGIN_URL"`
+}
+
+// Account — подтверждение email и сброс пароля. Ссылки в письмах ведут
+// на фронтенд PublicURL, который передаёт токен в API.
+type Account struct {
+	PublicURL string        `yaml:"publicUrl" env:"PUBLIC_URL"`
+	VerifyTTL time.Duration `yaml:"verifyTTL" env:"EMAIL_VERIFY_TTL"`
+	ResetTTL  time.Duration `yaml:"resetTTL" env:"PASSWORD_RESET_TTL"`
+}
+
+// Admin — логины, которым при запуске выдаётся роль admin. Так назначается первый
+// администратор; остальных можно назначать через /api/admin/.
+type Admin struct {
+	Logins []string `yaml:"logins" env:"ADMIN_LOGINS"`
+}
+
+// Quota — лимиты пользователя по умолчанию; 0 — без ограничения.
+// Отдельным пользователям их переопределяет администратор.
+type Quota struct {
+	// MaxBytes — исходники книг и собранные экспорты, в байтах
+	MaxBytes int64 `yaml:"maxBytes" env:"QUOTA_MAX_BYTES"`
+	MaxBooks int64 `yaml:"maxBooks" env:"QUOTA_MAX_BOOKS"`
+	// MonthlyChars — символов, синтезированных TTS за календарный месяц (UTC)
+	MonthlyChars int64 `yaml:"monthlyChars" env:"QUOTA_MONTHLY_CHARS"`
+}
+
+// Library — публичный каталог: администраторы публикуют книгу один раз, пользователи
+// добавляют её в коллекцию по ссылке. Загрузка книги, которая уже есть в каталоге,
+// тоже превращается в ссылку. Выключенный каталог скрывает свои маршруты, но уже
+// добавленные книги остаются в коллекциях.
+type Library struct {
+	Enabled bool `yaml:"enabled" env:"LIBRARY_ENABLED"`
+}
+
+// Mail — отправка писем. Driver: smtp, file (письма .eml в Dir) или log
+type Mail struct {
+	Driver       string `yaml:"driver" env:"MAIL_DRIVER"`
+	From         string `yaml:"from" env:"MAIL_FROM"`
+	Dir          string `yaml:"dir" env:"MAIL_DIR"`
+	SMTPHost     string `yaml:"smtpHost" env:"SMTP_HOST"`
+	SMTPPort     int    `yaml:"smtpPort" env:"SMTP_PORT"`
+	SMTPUser     string `yaml:"smtpUser" env:"SMTP_USER"`
+	SMTPPassword string `yaml:"smtpPassword" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+type Tracing struct {
+	// otlp, console или none; адрес OTLP берётся из стандартного OTEL_EXPORTER_OTLP_ENDPOINT
+	Exporter string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+}
+
+type Health struct {
+	CacheTTL     time.Duration `yaml:"cacheTTL" env:"READY_CACHE_TTL"`
+	CheckTimeout time.Duration `yaml:"checkTimeout" env:"READY_CHECK_TIMEOUT"`
+}
+
+// CORS — политика CORS. Origin задаётся точно ("https://app.example.com"),
+// маской поддоменов ("https://*.example.com") или "*" для любого источника;
+// "*" несовместим с AllowCredentials.
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowedOrigins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string      `yaml:"allowedMethods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string      `yaml:"allowedHeaders" env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string      `yaml:"exposedHeaders" env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool          `yaml:"allowCredentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"maxAge" env:"CORS_MAX_AGE"`
+}
+
+// RateLimit — лимиты на вход и регистрацию. Store: memory для одной реплики,
+// postgres — общие лимиты для нескольких. TrustedHops — сколько своих прокси стоят перед
+// сервером и дописывают адрес в X-Forwarded-For (учитывается при trustProxy).
+type RateLimit struct {
+	Store            string        `yaml:"store" env:"RATE_LIMIT_STORE"`
+	TrustProxy       bool          `yaml:"trustProxy" env:"RATE_LIMIT_TRUST_PROXY"`
+	TrustedHops      int           `yaml:"trustedHops" env:"RATE_LIMIT_TRUSTED_HOPS"`
+	IPPerMinute      float64       `yaml:"ipPerMinute" env:"AUTH_IP_PER_MIN"`
+	IPBurst          int           `yaml:"ipBurst" env:"AUTH_IP_BURST"`
+	LoginPerMinute   float64       `yaml:"loginPerMinute" env:"AUTH_LOGIN_PER_MIN"`
+	LoginBurst       int           `yaml:"loginBurst" env:"AUTH_LOGIN_BURST"`
+	LockoutThreshold int           `yaml:"lockoutThreshold" env:"LOCKOUT_THRESHOLD"`
+	LockoutBase      time.Duration `yaml:"lockoutBase" env:"LOCKOUT_BASE"`
+	LockoutMax       time.Duration `yaml:"lockoutMax" env:"LOCKOUT_MAX"`
+}
+
+// Default — значения, с которыми сервер работал до появления конфигурации
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Host:              "0.0.0.0",
+			Port:              8080,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       60 * time.Second,
+			WriteTimeout:      120 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Log: Log{Level: "info", Format: "text"},
+		Database: Database{
+			Host:    "localhost",
+			Port:    5432,
+			User:    "postgres",
+			Name:    "voicebook",
+			SSLMode: "disable",
+		},
+		S3: S3{
+			Region:   "ru-central1",
+			Endpoint: "https://storage.yandexcloud.net",
+		},
+		TTS: TTS{URL: "http://158.160.73.166:8000"},
+		Auth: Auth{
+			Issuer:     "voicebook",
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		OIDC: OIDC{Scopes: []string{"openid", "email", "profile"}},
+		Account: Account{
+			PublicURL: "http://localhost:5173",
+			VerifyTTL: 24 * time.Hour,
+			ResetTTL:  time.Hour,
+		},
+		Quota: Quota{
+			MaxBytes:     500 << 20,
+			MaxBooks:     200,
+			MonthlyChars: 2_000_000,
+		},
+		Mail: Mail{
+			Driver:   "log",
+			From:     "Voicebook <no-reply@localhost>",
+			Dir:      "mail",
+			SMTPPort: 587,
+		},
+		Tracing: Tracing{Exporter: "none"},
+		Health: Health{
+			CacheTTL:     5 * time.Second,
+			CheckTimeout: 3 * time.Second,
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{"http://127.0.0.1:5173", "http://localhost:5173", "http://158.160.73.166"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-Session-Id", "X-Request-Id", "Range"},
+			// Content-Range и Accept-Ranges нужны плееру для перемотки, X-Request-Id — для отладки
+			ExposedHeaders: []string{"X-Request-Id", "Retry-After", "Content-Range", "Content-Length", "Accept-Ranges"},
+			MaxAge:         10 * time.Minute,
+		},
+		RateLimit: RateLimit{
+			Store:            "memory",
+			TrustedHops:      1,
+			IPPerMinute:      20,
+			IPBurst:          10,
+			LoginPerMinute:   5,
+			LoginBurst:       5,
+			LockoutThreshold: 5,
+			LockoutBase:      30 * time.Second,
+			LockoutMax:       time.Hour,
+		},
+	}
+}
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port: must be between 1 and 65535, got %d", c.Server.Port)
+	for name, d := range map[string]time.Duration{
+		"server.readHeaderTimeout": c.Server.ReadHeaderTimeout,
+		"server.readTimeout":       c.Server.ReadTimeout,
+		"server.writeTimeout":      c.Server.WriteTimeout,
+		"server.idleTimeout":       c.Server.IdleTimeout,
+		"server.shutdownTimeout":   c.Server.ShutdownTimeout,
+		"health.cacheTTL":          c.Health.CacheTTL,
+		"health.checkTimeout":      c.Health.CheckTimeout,
+	} {
+		check(d > 0, "%s: must be positive, got %s", name, d)
+	}
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level: unknown level %q", c.Log.Level)
+	check(oneOf(strings.ToLower(c.Log.Format), "text", "json"), "log.format: must be text or json, got %q", c.Log.Format)
+
+	if c.Database.URL == "" {
+		check(c.Database.Host != "", "database.host: required when database.url is empty")
+		check(c.Database.Port > 0 && c.Database.Port < 65536, "database.port: must be between 1 and 65535, got %d", c.Database.Port)
+		check(c.Database.User != "", "database.user: required when database.url is empty")
+		check(c.Database.Name != "", "database.name: required when database.url is empty")
+	}
+
+	check(c.S3.AccessKey != "", "s3.accessKey: required (AWS_ACCESS_KEY_ID or YC_S3_API_KEY)")
+	check(c.S3.SecretKey != "", "s3.secretKey: required (AWS_SECRET_ACCESS_KEY or YC_S3_API_SECRET)")
+	check(c.S3.Bucket != "", "s3.bucket: required (AWS_BUCKET, S3_BUCKET or YC_S3_BUCKET)")
+	check(validURL(c.S3.Endpoint), "s3.endpoint: must be an absolute URL, got %q", c.S3.Endpoint)
+
+	check(validURL(c.TTS.URL), "tts.url: must be an absolute URL, got %q", c.TTS.URL)
+	if c.Auth.Tokens {
+		check(len(c.Auth.Keys) > 0, "auth.keys: at least one signing key is required when tokens are enabled")
+		check(c.Auth.AccessTTL > 0, "auth.accessTTL: must be positive")
+		check(c.Auth.RefreshTTL > c.Auth.AccessTTL, "auth.refreshTTL: must be longer than accessTTL")
+		for i, k := range c.Auth.Keys {
+			check(strings.Count(k, ":") >= 2, "auth.keys[%d]: must look like kid:alg:base64", i)
+		}
+	}
+	if c.OIDC.Enabled {
+		check(validURL(c.OIDC.Issuer), "oidc.issuer: must be an absolute URL, got %q", c.OIDC.Issuer)
+		check(c.OIDC.ClientID != "", "oidc.clientId: required when oidc is enabled")
+		check(slices.Contains(c.OIDC.Scopes, "openid"), "oidc.scopes: must include openid")
+		check(validURL(c.OIDC.RedirectURL), "oidc.redirectUrl: must be an absolute URL, got %q", c.OIDC.RedirectURL)
+		check(validURL(c.OIDC.PostLoginURL), "oidc.postLoginUrl: must be an absolute URL, got %q", c.OIDC.PostLoginURL)
+	}
+	check(validURL(c.Account.PublicURL), "account.publicUrl: must be an absolute URL, got %q", c.Account.PublicURL)
+	check(c.Account.VerifyTTL > 0, "account.verifyTTL: must be positive")
+	check(c.Account.ResetTTL > 0, "account.resetTTL: must be positive")
+	check(c.Quota.MaxBytes >= 0, "quota.maxBytes: must not be negative")
+	check(c.Quota.MaxBooks >= 0, "quota.maxBooks: must not be negative")
+	check(c.Quota.MonthlyChars >= 0, "quota.monthlyChars: must not be negative")
+	check(oneOf(c.Mail.Driver, "log", "file", "smtp"), "mail.driver: must be log, file or smtp, got %q", c.Mail.Driver)
+	check(c.Mail.From != "", "mail.from: required")
+	if c.Mail.Driver == "smtp" {
+		check(c.Mail.SMTPHost != "", "mail.smtpHost: required for smtp driver")
+		check(c.Mail.SMTPPort > 0 && c.Mail.SMTPPort < 65536, "mail.smtpPort: must be between 1 and 65535, got %d", c.Mail.SMTPPort)
+	}
+	if c.Mail.Driver == "file" {
+		check(c.Mail.Dir != "", "mail.dir: required for file driver")
+	}
+	check(oneOf(c.Tracing.Exporter, "", "none", "otlp", "console", "stdout"),
+		"tracing.exporter: must be otlp, console or none, got %q", c.Tracing.Exporter)
+
+	check(c.CORS.MaxAge >= 0, "cors.maxAge: must not be negative, got %s", c.CORS.MaxAge)
+	// "*" с credentials открыл бы любому сайту чтение ответов от имени пользователя
+	check(!c.CORS.AllowCredentials || !slices.Contains(c.CORS.AllowedOrigins, "*"),
+		"cors.allowCredentials: cannot be combined with allowedOrigins \"*\"")
+
+	check(oneOf(c.RateLimit.Store, "memory", "postgres"), "rateLimit.store: must be memory or postgres, got %q", c.RateLimit.Store)
+	check(!c.RateLimit.TrustProxy || c.RateLimit.TrustedHops > 0, "rateLimit.trustedHops: must be positive when trustProxy is set")
+	check(c.RateLimit.IPPerMinute > 0, "rateLimit.ipPerMinute: must be positive")
+	check(c.RateLimit.IPBurst > 0, "rateLimit.ipBurst: must be positive")
+	check(c.RateLimit.LoginPerMinute > 0, "rateLimit.loginPerMinute: must be positive")
+	check(c.RateLimit.LoginBurst > 0, "rateLimit.loginBurst: must be positive")
+	if c.RateLimit.LockoutThreshold > 0 {
+		check(c.RateLimit.LockoutBase > 0, "rateLimit.lockoutBase: must be positive when lockout is enabled")
+		check(c.RateLimit.LockoutMax >= c.RateLimit.LockoutBase, "rateLimit.lockoutMax: must not be less than lockoutBase")
+	}
+
+	// порядок ошибок не должен зависеть от обхода map
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+func oneOf(v string, allowed ...string) bool {
+	return slices.Contains(allowed, v)
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig — конфигурация по умолчанию с обязательными полями, которые в ней пусты
+func validConfig() *Config {
+	c := Default()
+	c.S3.AccessKey = "key"
+	c.S3.SecretKey = "secret"
+	c.S3.Bucket = "bucket"
+	return c
+}
+
+func TestValidateCORS(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		origins     []string
+		credentials bool
+		wantErr     string
+	}{
+		{"exact origins with credentials", []string{"https://app.example.com"}, true, ""},
+		{"subdomain mask with credentials", []string{"https://*.example.com"}, true, ""},
+		{"any origin without credentials", []string{"*"}, false, ""},
+		{"any origin with credentials", []string{"https://app.example.com", "*"}, true, "cors.allowCredentials"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validConfig()
+			c.CORS.AllowedOrigins = tc.origins
+			c.CORS.AllowCredentials = tc.credentials
+			err := c.Validate()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("err = %v, want mention of %s", err, tc.wantErr)
+			}
+		})
+	}
+}
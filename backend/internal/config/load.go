@@ -0,0 +1,197 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "******"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load собирает конфигурацию из значений по умолчанию, файла path (если задан)
+// и окружения, включая .env в рабочем каталоге. Ошибки разбора и проверки
+// возвращаются все вместе, чтобы их можно было исправить за один заход;
+// при ошибках проверки вместе с ними возвращается и собранная конфигурация.
+func Load(path string) (*Config, error) {
+	// .env не перекрывает уже заданные переменные
+	_ = godotenv.Load(".env")
+
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	errs := applyEnv(reflect.ValueOf(cfg).Elem())
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return cfg, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// applyEnv переносит в структуру значения из переменных окружения по тегам env
+func applyEnv(v reflect.Value) []error {
+	var errs []error
+	for i := 0; i < v.NumField(); i++ {
+		field, sf := v.Field(i), v.Type().Field(i)
+		if field.Kind() == reflect.Struct {
+			errs = append(errs, applyEnv(field)...)
+			continue
+		}
+		tag := sf.Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+		for _, name := range strings.Split(tag, ",") {
+			raw := os.Getenv(name)
+			if raw == "" {
+				continue
+			}
+			if err := setValue(field, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid value %q: %w", name, raw, err))
+			}
+			break
+		}
+	}
+	return errs
+}
+
+func setValue(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		// список через запятую; пустые элементы отбрасываются
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// Redacted возвращает копию, в которой секреты замаскированы
+func (c *Config) Redacted() *Config {
+	cp := *c
+	redact(reflect.ValueOf(&cp).Elem())
+	return &cp
+}
+
+func redact(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field, sf := v.Field(i), v.Type().Field(i)
+		if field.Kind() == reflect.Struct {
+			redact(field)
+			continue
+		}
+		tag := sf.Tag.Get("secret")
+		// срез делится с исходной конфигурацией, поэтому маскируется копия
+		if field.Kind() == reflect.Slice && tag == "true" && field.Len() > 0 {
+			masked := make([]string, field.Len())
+			for j := range masked {
+				masked[j] = redacted
+			}
+			field.Set(reflect.ValueOf(masked))
+			continue
+		}
+		if field.Kind() != reflect.String || field.String() == "" {
+			continue
+		}
+		switch tag {
+		case "true":
+			field.SetString(redacted)
+		case "url":
+			field.SetString(redactDSN(field.String()))
+		}
+	}
+}
+
+// пароль в строке подключения libpq вида "host=db password=secret" или "password='a b'"
+var dsnPasswordRe = regexp.MustCompile(`(?i)(^|\s)((?:ssl)?password\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactDSN маскирует пароль в строке подключения к базе: в userinfo и параметре
+// запроса password у URL, в ключе password у строки вида "ключ=значение"
+func redactDSN(dsn string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsnPasswordRe.ReplaceAllString(dsn, "${1}${2}"+redacted)
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return redacted
+	}
+	// порядок параметров сохраняется, поэтому url.Values не подходит
+	params := strings.Split(u.RawQuery, "&")
+	for i, p := range params {
+		key, _, _ := strings.Cut(p, "=")
+		if k, err := url.QueryUnescape(key); err == nil && (strings.EqualFold(k, "password") || strings.EqualFold(k, "sslpassword")) {
+			params[i] = key + "=" + redacted
+		}
+	}
+	u.RawQuery = strings.Join(params, "&")
+	return u.Redacted()
+}
+
+// Print пишет итоговую конфигурацию в YAML без секретов (флаг --print-config)
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setRequiredEnv задаёт поля, без которых конфигурация не проходит проверку
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_BUCKET", "bucket")
+}
+
+func writeConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// окружение перекрывает файл, файл — значения по умолчанию
+func TestLoadPrecedence(t *testing.T) {
+	setRequiredEnv(t)
+	path := writeConfig(t, `
+server:
+  port: 9000
+  readTimeout: 30s
+log:
+  level: debug
+cors:
+  allowedOrigins: ["https://file.example.com"]
+`)
+	t.Setenv("PORT", "9100")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example.com, ,https://b.example.com")
+	t.Setenv("RATE_LIMIT_TRUST_PROXY", "true")
+	t.Setenv("RATE_LIMIT_TRUSTED_HOPS", "2")
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Port != 9100 {
+		t.Errorf("server.port = %d, want 9100 from env", c.Server.Port)
+	}
+	if c.Server.ReadTimeout != 30*time.Second {
+		t.Errorf("server.readTimeout = %s, want 30s from file", c.Server.ReadTimeout)
+	}
+	if c.Log.Level != "debug" {
+		t.Errorf("log.level = %q, want debug from file", c.Log.Level)
+	}
+	if c.Server.Host != Default().Server.Host {
+		t.Errorf("server.host = %q, want default", c.Server.Host)
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com"}; !slices.Equal(c.CORS.AllowedOrigins, want) {
+		t.Errorf("cors.allowedOrigins = %q, want %q", c.CORS.AllowedOrigins, want)
+	}
+	if !c.RateLimit.TrustProxy || c.RateLimit.TrustedHops != 2 {
+		t.Errorf("rateLimit = %+v, want trustProxy with 2 hops", c.RateLimit)
+	}
+}
+
+// из нескольких имён переменной побеждает первое непустое
+func TestLoadEnvAliases(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("AWS_BUCKET", "")
+	t.Setenv("S3_BUCKET", "")
+	t.Setenv("YC_S3_BUCKET", "legacy")
+	c, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.S3.Bucket != "legacy" {
+		t.Errorf("s3.bucket = %q, want legacy", c.S3.Bucket)
+	}
+
+	t.Setenv("AWS_BUCKET", "current")
+	if c, err = Load(""); err != nil {
+		t.Fatal(err)
+	}
+	if c.S3.Bucket != "current" {
+		t.Errorf("s3.bucket = %q, want current", c.S3.Bucket)
+	}
+}
+
+// ошибки разбора окружения и проверки приходят все сразу, а конфигурация
+// возвращается вместе с ними, чтобы --print-config мог её показать
+func TestLoadErrors(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("PORT", "eighty")
+	t.Setenv("HTTP_READ_TIMEOUT", "soon")
+	t.Setenv("LOG_LEVEL", "loud")
+	t.Setenv("RATE_LIMIT_TRUST_PROXY", "true")
+	t.Setenv("RATE_LIMIT_TRUSTED_HOPS", "0")
+
+	c, err := Load("")
+	if err == nil {
+		t.Fatal("Load with invalid values: want error")
+	}
+	if c == nil {
+		t.Fatal("Load must return the config together with validation errors")
+	}
+	for _, want := range []string{"PORT", "HTTP_READ_TIMEOUT", "log.level", "rateLimit.trustedHops"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	setRequiredEnv(t)
+	for name, yaml := range map[string]string{
+		"unknown field": "server:\n  prot: 80\n",
+		"wrong type":    "server:\n  port: eighty\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, yaml)); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("missing file: want error")
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	for _, tc := range []struct {
+		dsn, want string
+	}{
+		{"", ""},
+		{"postgres://app:hunter2@db:5432/voicebook?sslmode=disable", "postgres://app:xxxxx@db:5432/voicebook?sslmode=disable"},
+		{"postgres://app@db/voicebook?sslmode=disable&password=hunter2", "postgres://app@db/voicebook?sslmode=disable&password=******"},
+		{"postgres://db/voicebook?PASSWORD=hunter2&user=app", "postgres://db/voicebook?PASSWORD=******&user=app"},
+		{"postgres://db/voicebook?sslpassword=hunter2", "postgres://db/voicebook?sslpassword=******"},
+		{"host=db user=app password=hunter2 dbname=voicebook", "host=db user=app password=****** dbname=voicebook"},
+		{"host=db password = 'hunter 2' dbname=voicebook", "host=db password = ****** dbname=voicebook"},
+		{`password='it\'s secret' host=db`, "password=****** host=db"},
+		{"host=db sslpassword=hunter2", "host=db sslpassword=******"},
+		{"host=db user=app", "host=db user=app"},
+	} {
+		if got := redactDSN(tc.dsn); got != tc.want {
+			t.Errorf("redactDSN(%q) = %q, want %q", tc.dsn, got, tc.want)
+		}
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "host=db user=app password=hunter2"
+	cfg.Database.Password = "hunter2"
+	cfg.S3.SecretKey = "hunter2"
+	cfg.Auth.Keys = []string{"k1:HS256:aHVudGVyMg=="}
+	cfg.OIDC.ClientSecret = "hunter2"
+	cfg.Mail.SMTPPassword = "hunter2"
+
+	var out strings.Builder
+	if err := cfg.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter2", "aHVudGVyMg=="} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("printed config contains %q:\n%s", secret, out.String())
+		}
+	}
+	// маскируется копия: исходная конфигурация нужна серверу целиком
+	if cfg.Auth.Keys[0] != "k1:HS256:aHVudGVyMg==" || cfg.Database.Password != "hunter2" {
+		t.Error("Print changed the original config")
+	}
+}
@@ -0,0 +1,79 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"voicebook/internal/ssml"
+	"voicebook/internal/storage"
+)
+
+// ErrChapterNotFound — в книге нет главы с таким номером
+var ErrChapterNotFound = errors.New("chapter not found")
+
+type Chapter struct {
+	Index     int    `json:"index"`
+	Title     string `json:"title"`
+	FirstPage int    `json:"firstPage"`
+	LastPage  int    `json:"lastPage"`
+}
+
+// Chapters делит книгу на главы по заголовкам в тексте страниц. Страницы до первого
+// заголовка становятся главой с названием книги; книга без заголовков — одна глава.
+func Chapters(pages []storage.Page, bookTitle string) []Chapter {
+	var chapters []Chapter
+	for _, p := range pages {
+		title := OpeningHeading(p, len(chapters) == 0, bookTitle)
+		if title == "" && len(chapters) > 0 {
+			chapters[len(chapters)-1].LastPage = p.PageIdx
+			continue
+		}
+		chapters = append(chapters, Chapter{
+			Index:     len(chapters) + 1,
+			Title:     title,
+			FirstPage: p.PageIdx,
+			LastPage:  p.PageIdx,
+		})
+	}
+	return chapters
+}
+
+// Select оставляет страницы и главы, которые войдут в экспорт главы chapter (с 1); nil — вся книга
+func Select(pages []storage.Page, bookTitle string, chapter *int) ([]storage.Page, []Chapter, error) {
+	chapters := Chapters(pages, bookTitle)
+	if chapter == nil {
+		return pages, chapters, nil
+	}
+	idx := *chapter - 1
+	if idx < 0 || idx >= len(chapters) {
+		return nil, nil, fmt.Errorf("chapter %d: %w", *chapter, ErrChapterNotFound)
+	}
+	ch := chapters[idx]
+	return pagesBetween(pages, ch.FirstPage, ch.LastPage), []Chapter{ch}, nil
+}
+
+// OpeningHeading — заголовок главы, которую открывает страница: заголовки на ней самой,
+// а у первой страницы книги без заголовков — название книги. "" — страница продолжает главу.
+func OpeningHeading(p storage.Page, first bool, bookTitle string) string {
+	if title := pageHeading(p.Text); title != "" {
+		return title
+	}
+	if first {
+		return bookTitle
+	}
+	return ""
+}
+
+// pageHeading возвращает заголовки, найденные на странице, через точку
+// ("Часть первая. Глава 1")
+func pageHeading(text string) string {
+	var headings []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && ssml.IsHeading(line) {
+			headings = append(headings, strings.TrimRight(line, ".:"))
+		}
+	}
+	return strings.Join(headings, ". ")
+}
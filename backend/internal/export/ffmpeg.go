@@ -0,0 +1,47 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"voicebook/internal/transcode"
+)
+
+// encodeFFmpeg склеивает WAV-файлы страниц в один файл с главами и метаданными
+func encodeFFmpeg(ctx context.Context, enc *transcode.FFmpeg, dir string, wavs []string, format, title, author string, chapters []chapterMark, out string) error {
+	if enc == nil {
+		return transcode.ErrNoEncoder
+	}
+
+	var list strings.Builder
+	for _, wav := range wavs {
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(wav, "'", `'\''`))
+	}
+	listPath := filepath.Join(dir, "list.txt")
+	if err := os.WriteFile(listPath, []byte(list.String()), 0o600); err != nil {
+		return err
+	}
+
+	var meta strings.Builder
+	meta.WriteString(";FFMETADATA1\n")
+	fmt.Fprintf(&meta, "title=%s\nalbum=%s\nartist=%s\n", ffmetaEscape(title), ffmetaEscape(title), ffmetaEscape(author))
+	for _, ch := range chapters {
+		fmt.Fprintf(&meta, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n", ch.startMs, ch.endMs, ffmetaEscape(ch.title))
+	}
+	metaPath := filepath.Join(dir, "meta.txt")
+	if err := os.WriteFile(metaPath, []byte(meta.String()), 0o600); err != nil {
+		return err
+	}
+
+	return enc.Encode(ctx, []string{
+		"-f", "concat", "-safe", "0", "-i", listPath,
+		"-i", metaPath, "-map_metadata", "1", "-map_chapters", "1", "-map", "0:a",
+	}, format, out)
+}
+
+func ffmetaEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", `\`+"\n").Replace(s)
+}
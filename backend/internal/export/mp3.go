@@ -0,0 +1,128 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// битрейты Layer III, кбит/с: MPEG-1 и MPEG-2/2.5
+var (
+	mp3BitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3BitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mp3Rates      = map[int][3]int{3: {44100, 48000, 32000}, 2: {22050, 24000, 16000}, 0: {11025, 12000, 8000}}
+)
+
+// stripMP3 убирает ID3-теги и служебный кадр Xing/Info, чтобы MP3 страниц можно было
+// склеить в один поток: иначе плееры берут длительность из первого кадра Xing
+func stripMP3(data []byte) []byte {
+	if len(data) >= 10 && string(data[:3]) == "ID3" {
+		size := int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9])
+		end := 10 + size
+		if data[5]&0x10 != 0 {
+			end += 10 // footer
+		}
+		if end <= len(data) {
+			data = data[end:]
+		}
+	}
+	if len(data) >= 128 && string(data[len(data)-128:len(data)-125]) == "TAG" {
+		data = data[:len(data)-128]
+	}
+
+	if n := mp3FrameLen(data); n > 0 && n <= len(data) {
+		head := data[:n]
+		if bytes.Contains(head, []byte("Xing")) || bytes.Contains(head, []byte("Info")) {
+			data = data[n:]
+		}
+	}
+	return data
+}
+
+// mp3FrameLen возвращает длину первого кадра Layer III или 0, если заголовок не распознан
+func mp3FrameLen(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1]&0xE0 != 0xE0 {
+		return 0
+	}
+	version := int(data[1]>>3) & 0x03
+	layer := int(data[1]>>1) & 0x03
+	if version == 1 || layer != 1 {
+		return 0
+	}
+	bitrateIdx := int(data[2] >> 4)
+	rateIdx := int(data[2]>>2) & 0x03
+	padding := int(data[2]>>1) & 0x01
+	if rateIdx == 3 {
+		return 0
+	}
+
+	rate := mp3Rates[version][rateIdx]
+	if version == 3 {
+		return 144*mp3BitratesV1[bitrateIdx]*1000/rate + padding
+	}
+	return 72*mp3BitratesV2[bitrateIdx]*1000/rate + padding
+}
+
+type chapterMark struct {
+	title   string
+	startMs int64
+	endMs   int64
+}
+
+// id3Tag собирает тег ID3v2.3 с названием, автором и главами (фреймы CHAP/CTOC)
+func id3Tag(title, author string, chapters []chapterMark) []byte {
+	var frames bytes.Buffer
+	frames.Write(id3Frame("TIT2", id3Text(title)))
+	frames.Write(id3Frame("TALB", id3Text(title)))
+	if author != "" {
+		frames.Write(id3Frame("TPE1", id3Text(author)))
+	}
+
+	// в CTOC помещается не больше 255 глав
+	if len(chapters) > 255 {
+		chapters = chapters[:255]
+	}
+	if len(chapters) > 0 {
+		var toc bytes.Buffer
+		toc.WriteString("toc\x00")
+		toc.WriteByte(0x03) // верхний уровень, упорядоченный
+		toc.WriteByte(byte(len(chapters)))
+		for i := range chapters {
+			fmt.Fprintf(&toc, "ch%d\x00", i)
+		}
+		frames.Write(id3Frame("CTOC", toc.Bytes()))
+
+		for i, ch := range chapters {
+			var chap bytes.Buffer
+			fmt.Fprintf(&chap, "ch%d\x00", i)
+			binary.Write(&chap, binary.BigEndian, uint32(ch.startMs))
+			binary.Write(&chap, binary.BigEndian, uint32(ch.endMs))
+			binary.Write(&chap, binary.BigEndian, uint32(0xFFFFFFFF))
+			binary.Write(&chap, binary.BigEndian, uint32(0xFFFFFFFF))
+			chap.Write(id3Frame("TIT2", id3Text(ch.title)))
+			frames.Write(id3Frame("CHAP", chap.Bytes()))
+		}
+	}
+
+	size := frames.Len()
+	header := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size>>21) & 0x7F, byte(size>>14) & 0x7F, byte(size>>7) & 0x7F, byte(size) & 0x7F}
+	return append(header, frames.Bytes()...)
+}
+
+func id3Frame(id string, data []byte) []byte {
+	frame := make([]byte, 10, 10+len(data))
+	copy(frame, id)
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(data)))
+	return append(frame, data...)
+}
+
+// id3Text кодирует строку в UTF-16 с BOM — в ID3v2.3 нет UTF-8, а названия бывают на кириллице
+func id3Text(s string) []byte {
+	buf := []byte{0x01, 0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		buf = append(buf, byte(u), byte(u>>8))
+	}
+	return buf
+}
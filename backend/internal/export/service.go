@@ -0,0 +1,367 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"voicebook/internal/logging"
+	"voicebook/internal/metrics"
+	"voicebook/internal/s3client"
+	"voicebook/internal/storage"
+	"voicebook/internal/timing"
+	"voicebook/internal/tracing"
+	"voicebook/internal/transcode"
+	"voicebook/internal/tts"
+)
+
+// одновременно собирается не больше стольких книг, чтобы не забить TTS
+const maxParallelJobs = 2
+
+// Formats — форматы, в которые собирается книга
+var Formats = []string{transcode.MP3, transcode.M4B, transcode.OggOpus}
+
+// ParseFormat приводит имя формата экспорта к значению из Formats: "opus" и "ogg" — это OggOpus
+func ParseFormat(s string) (string, bool) {
+	f, ok := transcode.ParseFormat(s)
+	if !ok && strings.EqualFold(strings.TrimSpace(s), transcode.M4B) {
+		f, ok = transcode.M4B, true
+	}
+	return f, ok && slices.Contains(Formats, f)
+}
+
+type Service struct {
+	st  *storage.Storage
+	cl  *s3client.Client
+	tts *tts.Client
+	// enc — nil, если ffmpeg недоступен: тогда собирается только MP3
+	enc *transcode.FFmpeg
+	// quota — лимиты по умолчанию; синтез в задаче расходует месячный лимит владельца
+	quota  storage.Quota
+	logger *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sem    chan struct{}
+}
+
+func New(st *storage.Storage, cl *s3client.Client, tc *tts.Client, enc *transcode.FFmpeg, quota storage.Quota, logger *slog.Logger) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		st:     st,
+		cl:     cl,
+		tts:    tc,
+		enc:    enc,
+		quota:  quota,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+		sem:    make(chan struct{}, maxParallelJobs),
+	}
+}
+
+// Resume перезапускает задачи, которые не успели завершиться до остановки сервера
+func (s *Service) Resume() error {
+	jobs, err := s.st.GetUnfinishedExportJobs(s.ctx)
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		s.Enqueue(j)
+	}
+	return nil
+}
+
+func (s *Service) Enqueue(job storage.ExportJob) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		select {
+		case s.sem <- struct{}{}:
+		case <-s.ctx.Done():
+			return
+		}
+		defer func() { <-s.sem }()
+
+		s.run(job)
+	}()
+}
+
+// Shutdown ждёт завершения задач; по истечении ctx прерывает их,
+// и они остаются в статусе pending до следующего запуска
+func (s *Service) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *Service) run(job storage.ExportJob) {
+	logger := s.logger.With("export_id", job.ID, "book_id", job.BookID, "format", job.Format)
+	logger.Info("export started")
+
+	ctx, span := tracing.Tracer().Start(s.ctx, "export.build", trace.WithAttributes(
+		attribute.Int64("export.id", job.ID),
+		attribute.Int64("book.id", job.BookID),
+		attribute.String("export.format", job.Format),
+	))
+	defer span.End()
+	ctx = logging.WithLogger(ctx, logger)
+	// статус нужно записать и после отмены задачи при остановке сервера
+	stCtx := context.WithoutCancel(ctx)
+
+	if err := s.st.UpdateExportJob(stCtx, job.ID, storage.ExportRunning, "", ""); err != nil {
+		logger.Error("export status update failed", "error", err)
+		return
+	}
+	metrics.ExportStarted()
+
+	res, err := s.build(ctx, job)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Info("export interrupted")
+		metrics.ExportFinished(job.Format, "interrupted")
+		err = s.st.UpdateExportJob(stCtx, job.ID, storage.ExportPending, "", "")
+	case err != nil:
+		logger.Error("export failed", "error", err)
+		metrics.ExportFinished(job.Format, storage.ExportFailed)
+		err = s.st.UpdateExportJob(stCtx, job.ID, storage.ExportFailed, err.Error(), "")
+	default:
+		logger.Info("export finished")
+		metrics.ExportFinished(job.Format, storage.ExportDone)
+		err = s.st.FinishExportJob(stCtx, job.ID, res.url, res.durationMs, res.sizeBytes)
+	}
+	if err != nil {
+		logger.Error("export status update failed", "error", err)
+	}
+}
+
+type result struct {
+	url        string
+	durationMs int64
+	sizeBytes  int64
+}
+
+type pageAudio struct {
+	fileURL    string
+	durationMs int64
+}
+
+func (s *Service) build(ctx context.Context, job storage.ExportJob) (result, error) {
+	book, err := s.st.GetBook(ctx, job.BookID, job.Login)
+	if err != nil {
+		return result{}, fmt.Errorf("get book: %w", err)
+	}
+	pages, err := s.st.GetBookPages(ctx, job.BookID)
+	if err != nil {
+		return result{}, fmt.Errorf("get pages: %w", err)
+	}
+	if len(pages) == 0 {
+		return result{}, errors.New("book has no pages")
+	}
+	// первая страница книги, а не выбранной главы: её открывает название книги
+	firstPage := pages[0].PageIdx
+	pages, chapters, err := Select(pages, book.Title, job.Chapter)
+	if err != nil {
+		return result{}, err
+	}
+	title := book.Title
+	if job.Chapter != nil {
+		title = book.Title + ". " + chapters[0].Title
+	}
+
+	settings, err := s.st.GetEffectiveSettings(ctx, job.Login, job.BookID)
+	if err != nil {
+		return result{}, fmt.Errorf("get voice settings: %w", err)
+	}
+	quota, err := s.st.GetQuota(ctx, job.Login, s.quota)
+	if err != nil {
+		return result{}, fmt.Errorf("get quota: %w", err)
+	}
+	// MP3 склеивается как есть, остальные форматы кодирует ffmpeg из WAV
+	settings.Format = transcode.WAV
+	if job.Format == transcode.MP3 {
+		settings.Format = transcode.MP3
+	} else if s.enc == nil {
+		return result{}, transcode.ErrNoEncoder
+	}
+
+	// первый проход: синтез (или кеш) и длительности страниц для разметки глав
+	audio := make([]pageAudio, len(pages))
+	for i, p := range pages {
+		if err := ctx.Err(); err != nil {
+			return result{}, err
+		}
+		heading := OpeningHeading(p, p.PageIdx == firstPage, book.Title)
+		// озвученные страницы лимит не расходуют, поэтому сначала — кеш
+		res, err := s.tts.Lookup(ctx, p.Text, heading, settings)
+		if errors.Is(err, tts.ErrNotCached) {
+			res, err = s.synthesize(ctx, job.Login, quota, p.Text, heading, settings)
+		}
+		if err != nil {
+			return result{}, fmt.Errorf("synthesize page %d: %w", p.PageIdx, err)
+		}
+		audio[i] = pageAudio{fileURL: res.FileURL}
+		if res.MetaURL != "" {
+			data, err := s.cl.DownloadFile(ctx, res.MetaURL)
+			if err != nil {
+				return result{}, fmt.Errorf("download timings of page %d: %w", p.PageIdx, err)
+			}
+			var meta timing.Meta
+			if err := json.Unmarshal(data, &meta); err != nil {
+				return result{}, fmt.Errorf("parse timings of page %d: %w", p.PageIdx, err)
+			}
+			audio[i].durationMs = meta.DurationMs
+		}
+	}
+	marks := chapterMarks(chapters, pages, audio)
+	res := result{durationMs: marks[len(marks)-1].endMs}
+
+	dir, err := os.MkdirTemp("", "export-*")
+	if err != nil {
+		return result{}, err
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "book."+transcode.Extensions[job.Format])
+
+	if job.Format == transcode.MP3 {
+		err = s.concatMP3(ctx, out, title, book.Author, marks, audio)
+	} else {
+		var wavs []string
+		wavs, err = s.downloadAll(ctx, dir, audio)
+		if err == nil {
+			err = encodeFFmpeg(ctx, s.enc, dir, wavs, job.Format, title, book.Author, marks, out)
+		}
+	}
+	if err != nil {
+		return result{}, err
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		return result{}, err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil {
+		res.sizeBytes = fi.Size()
+	}
+
+	key := fmt.Sprintf("exports/%d.%s", job.ID, transcode.Extensions[job.Format])
+	res.url, err = s.cl.PutObject(ctx, key, f, transcode.ContentTypes[job.Format])
+	return res, err
+}
+
+// synthesize синтезирует страницу, списывая её символы из месячного лимита пользователя;
+// если лимит исчерпан, сборка останавливается с storage.ErrTTSQuotaExceeded
+func (s *Service) synthesize(ctx context.Context, login string, quota storage.Quota, text, heading string, vs storage.VoiceSettings) (tts.Result, error) {
+	month := storage.UsageMonth(time.Now())
+	chars := int64(utf8.RuneCountInString(text))
+	if err := s.st.ReserveSynthesizedChars(ctx, login, month, chars, quota.MonthlyChars); err != nil {
+		return tts.Result{}, err
+	}
+	res, err := s.tts.Synthesize(ctx, text, heading, vs)
+	if err != nil || res.Source == "cached" {
+		// страницу не синтезировали (или её успел озвучить параллельный запрос): символы возвращаются
+		if err := s.st.AddSynthesizedChars(context.WithoutCancel(ctx), login, month, -chars); err != nil {
+			logging.FromContext(ctx).Error("failed to release tts usage", "error", err)
+		}
+	}
+	return res, err
+}
+
+func (s *Service) concatMP3(ctx context.Context, out, title, author string, marks []chapterMark, audio []pageAudio) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(id3Tag(title, author, marks)); err != nil {
+		return err
+	}
+	for _, a := range audio {
+		data, err := s.cl.DownloadFile(ctx, a.fileURL)
+		if err != nil {
+			return fmt.Errorf("download audio: %w", err)
+		}
+		if _, err := f.Write(stripMP3(data)); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func (s *Service) downloadAll(ctx context.Context, dir string, audio []pageAudio) ([]string, error) {
+	paths := make([]string, len(audio))
+	for i, a := range audio {
+		data, err := s.cl.DownloadFile(ctx, a.fileURL)
+		if err != nil {
+			return nil, fmt.Errorf("download audio: %w", err)
+		}
+		paths[i] = filepath.Join(dir, fmt.Sprintf("%06d.wav", i))
+		if err := os.WriteFile(paths[i], data, 0o600); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// chapterMarks переводит границы глав из номеров страниц во время от начала файла
+func chapterMarks(chapters []Chapter, pages []storage.Page, audio []pageAudio) []chapterMark {
+	starts := make(map[int]int64, len(pages))
+	var elapsed int64
+	for i, p := range pages {
+		starts[p.PageIdx] = elapsed
+		elapsed += audio[i].durationMs
+	}
+
+	marks := make([]chapterMark, 0, len(chapters))
+	for i, ch := range chapters {
+		end := elapsed
+		if i+1 < len(chapters) {
+			end = starts[chapters[i+1].FirstPage]
+		}
+		marks = append(marks, chapterMark{title: ch.Title, startMs: starts[ch.FirstPage], endMs: end})
+	}
+	return marks
+}
+
+func pagesBetween(pages []storage.Page, first, last int) []storage.Page {
+	var res []storage.Page
+	for _, p := range pages {
+		if p.PageIdx >= first && p.PageIdx <= last {
+			res = append(res, p)
+		}
+	}
+	return res
+}
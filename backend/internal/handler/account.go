@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"voicebook/internal/logging"
+	"voicebook/internal/mailer"
+	"voicebook/internal/storage"
+	"voicebook/internal/token"
+	"voicebook/internal/validate"
+)
+
+type emailRequest struct {
+	Email string `json:"email"`
+}
+
+type tokenRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type resetRequest struct {
+	Login string `json:"login"`
+	Email string `json:"email"`
+}
+
+// PutEmail отправляет письмо с подтверждением на новый адрес; email пользователя
+// меняется только после перехода по ссылке из письма
+func (h *Handler) PutEmail(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+
+	var req emailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	var errs validate.Errors
+	errs.Check("email", validate.Email(req.Email))
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+
+	owner, err := h.st.GetLoginByEmail(r.Context(), req.Email)
+	switch {
+	case err == nil && owner != login:
+		writeStorageError(w, r, storage.ErrEmailExists, "")
+		return
+	case err != nil && !errors.Is(err, storage.ErrUserNotFound):
+		writeInternal(w, r, err, "failed to check email")
+		return
+	}
+
+	tok := randomString()
+	expires := time.Now().Add(h.auth.VerifyTTL)
+	if err := h.st.SaveUserToken(r.Context(), login, storage.TokenVerifyEmail, token.Hash(tok), req.Email, expires); err != nil {
+		writeInternal(w, r, err, "failed to save verification token")
+		return
+	}
+
+	err = h.auth.Mailer.Send(r.Context(), mailer.Message{
+		To:      req.Email,
+		Subject: "Подтверждение адреса",
+		Body: "Чтобы подтвердить адрес для входа в Voicebook, откройте ссылку:\n\n" +
+			h.accountLink("/verify-email", tok) + "\n\n" +
+			"Ссылка действует до " + expires.Format("02.01.2006 15:04 MST") + ".\n" +
+			"Если вы не указывали этот адрес, просто проигнорируйте письмо.\n",
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to send verification email", "error", err)
+		writeError(w, r, http.StatusBadGateway, codeUpstream, "failed to send email")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"message": "verification email sent"})
+}
+
+// VerifyEmail подтверждает адрес по токену из письма
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "token is required")
+		return
+	}
+
+	login, email, err := h.st.UseUserToken(r.Context(), storage.TokenVerifyEmail, token.Hash(req.Token))
+	if errors.Is(err, storage.ErrUserTokenInvalid) {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeInternal(w, r, err, "failed to check token")
+		return
+	}
+
+	if err := h.st.SetUserEmail(r.Context(), login, email); err != nil {
+		writeStorageError(w, r, err, "failed to save email")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"login": login, "email": email})
+}
+
+// RequestPasswordReset отправляет ссылку для сброса на подтверждённый email.
+// Ответ один и тот же, есть такой пользователь или нет, — чтобы по нему
+// нельзя было перебирать логины и адреса.
+func (h *Handler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req resetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	if req.Login == "" && req.Email == "" {
+		writeValidationError(w, r, validate.Errors{{Field: "login", Message: "login or email is required"}})
+		return
+	}
+
+	if err := h.sendPasswordReset(r, req); err != nil {
+		logging.FromContext(r.Context()).Error("failed to send password reset", "error", err)
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if the account has a verified email, a reset link has been sent",
+	})
+}
+
+func (h *Handler) sendPasswordReset(r *http.Request, req resetRequest) error {
+	ctx := r.Context()
+
+	login, email := req.Login, req.Email
+	var err error
+	if login != "" {
+		email, err = h.st.GetUserEmail(ctx, login)
+	} else {
+		login, err = h.st.GetLoginByEmail(ctx, email)
+	}
+	if errors.Is(err, storage.ErrUserNotFound) || (err == nil && email == "") {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tok := randomString()
+	expires := time.Now().Add(h.auth.ResetTTL)
+	if err := h.st.SaveUserToken(ctx, login, storage.TokenResetPassword, token.Hash(tok), email, expires); err != nil {
+		return err
+	}
+
+	return h.auth.Mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Сброс пароля",
+		Body: "Для пользователя " + login + " запрошен сброс пароля в Voicebook.\n" +
+			"Чтобы задать новый пароль, откройте ссылку:\n\n" +
+			h.accountLink("/reset-password", tok) + "\n\n" +
+			"Ссылка действует до " + expires.Format("02.01.2006 15:04 MST") + ".\n" +
+			"Если вы не запрашивали сброс, проигнорируйте письмо — пароль останется прежним.\n",
+	})
+}
+
+// ResetPassword задаёт новый пароль по токену из письма; все сессии пользователя закрываются
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "token is required")
+		return
+	}
+	// пароль проверяется до того, как токен будет погашен
+	var errs validate.Errors
+	errs.Check("password", validate.Password(req.Password))
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+
+	login, _, err := h.st.UseUserToken(r.Context(), storage.TokenResetPassword, token.Hash(req.Token))
+	if errors.Is(err, storage.ErrUserTokenInvalid) {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeInternal(w, r, err, "failed to check token")
+		return
+	}
+
+	if err := h.st.SetPassword(r.Context(), login, req.Password); err != nil {
+		writeStorageError(w, r, err, "failed to update password")
+		return
+	}
+	logging.FromContext(r.Context()).Info("password reset", "reset_login", login)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// accountLink — ссылка на страницу фронтенда с токеном
+func (h *Handler) accountLink(path, tok string) string {
+	return strings.TrimSuffix(h.auth.PublicURL, "/") + path + "?" + url.Values{"token": {tok}}.Encode()
+}
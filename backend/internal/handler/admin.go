@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"voicebook/internal/logging"
+	"voicebook/internal/storage"
+	"voicebook/internal/validate"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultUsersPage = 50
+	maxUsersPage     = 500
+)
+
+type patchUserRequest struct {
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+// adminExportJob — задача экспорта вместе с владельцем, который в обычном ответе скрыт
+type adminExportJob struct {
+	Login string `json:"login"`
+	storage.ExportJob
+}
+
+// AdminListUsers — пользователи с занятым местом, постранично (?limit=&offset=)
+func (h *Handler) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	limit, offset := defaultUsersPage, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxUsersPage {
+			writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "limit must be between 1 and "+strconv.Itoa(maxUsersPage))
+			return
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "offset must be a non-negative number")
+			return
+		}
+		offset = n
+	}
+
+	users, err := h.st.ListUsers(r.Context(), limit, offset)
+	if err != nil {
+		writeInternal(w, r, err, "failed to list users")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"users": users, "limit": limit, "offset": offset})
+}
+
+func (h *Handler) AdminGetUser(w http.ResponseWriter, r *http.Request) {
+	u, err := h.st.GetUserInfo(r.Context(), chi.URLParam(r, "login"))
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get user")
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// AdminPatchUser меняет роль и отключает или включает аккаунт.
+// Себя менять нельзя, чтобы не остаться без администратора.
+func (h *Handler) AdminPatchUser(w http.ResponseWriter, r *http.Request) {
+	admin := r.Context().Value("login").(string)
+	login := chi.URLParam(r, "login")
+
+	var req patchUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	if req.Role != nil && *req.Role != storage.RoleUser && *req.Role != storage.RoleAdmin {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "role must be user or admin")
+		return
+	}
+	if login == admin {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "cannot change your own account")
+		return
+	}
+
+	logger := logging.FromContext(r.Context())
+	if req.Role != nil {
+		if err := h.st.SetUserRole(r.Context(), login, *req.Role); err != nil {
+			writeStorageError(w, r, err, "failed to set role")
+			return
+		}
+		logger.Info("user role changed by admin", "target_login", login, "role", *req.Role)
+	}
+	if req.Disabled != nil {
+		if err := h.st.SetUserDisabled(r.Context(), login, *req.Disabled); err != nil {
+			writeStorageError(w, r, err, "failed to update account")
+			return
+		}
+		logger.Info("user access changed by admin", "target_login", login, "disabled", *req.Disabled)
+	}
+
+	h.AdminGetUser(w, r)
+}
+
+// AdminRevokeSessions закрывает все сессии пользователя; выданные access-токены
+// действуют до истечения
+func (h *Handler) AdminRevokeSessions(w http.ResponseWriter, r *http.Request) {
+	login := chi.URLParam(r, "login")
+	if _, err := h.st.GetUserInfo(r.Context(), login); err != nil {
+		writeStorageError(w, r, err, "failed to get user")
+		return
+	}
+	if err := h.st.RevokeUserSessions(r.Context(), login); err != nil {
+		writeInternal(w, r, err, "failed to revoke sessions")
+		return
+	}
+	logging.FromContext(r.Context()).Info("sessions revoked by admin", "target_login", login)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminPutQuota задаёт пользователю свои лимиты; null в поле возвращает лимит по умолчанию
+func (h *Handler) AdminPutQuota(w http.ResponseWriter, r *http.Request) {
+	login := chi.URLParam(r, "login")
+
+	var req storage.QuotaOverride
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	var errs validate.Errors
+	for _, f := range []struct {
+		name string
+		v    *int64
+	}{
+		{"maxBytes", req.MaxBytes},
+		{"maxBooks", req.MaxBooks},
+		{"monthlyChars", req.MonthlyChars},
+	} {
+		if f.v != nil && *f.v < 0 {
+			errs.Check(f.name, "must not be negative")
+		}
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+
+	if _, err := h.st.GetUserInfo(r.Context(), login); err != nil {
+		writeStorageError(w, r, err, "failed to get user")
+		return
+	}
+	if err := h.st.SetQuotaOverride(r.Context(), login, req); err != nil {
+		writeInternal(w, r, err, "failed to set quota")
+		return
+	}
+	logging.FromContext(r.Context()).Info("user quota changed by admin", "target_login", login)
+
+	q, u, err := h.usageFor(r, login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get usage")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"usage": u, "quota": q})
+}
+
+// AdminDeleteBook удаляет книгу любого пользователя. Строки удаляются первыми:
+// если S3 не ответит, останется лишний объект, но не книга без файла.
+func (h *Handler) AdminDeleteBook(w http.ResponseWriter, r *http.Request) {
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
+		return
+	}
+
+	book, urls, err := h.st.ForceDeleteBook(r.Context(), bookID)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to delete book")
+		return
+	}
+	logger := logging.FromContext(r.Context())
+	for _, u := range urls {
+		if err := h.cl.DeleteObject(r.Context(), u); err != nil {
+			logger.Error("failed to delete object of deleted book", "book_id", bookID, "url", u, "error", err)
+		}
+	}
+	logger.Info("book deleted by admin", "book_id", bookID, "owner", book.Login)
+
+	writeJSON(w, http.StatusOK, map[string]any{"book": book})
+}
+
+// AdminJobs — очереди фоновых задач: экспорты (синтез и сборка аудио) и удаления
+// аккаунтов. Загрузка книг синхронная и в очередь не попадает.
+func (h *Handler) AdminJobs(w http.ResponseWriter, r *http.Request) {
+	exports, err := h.st.GetUnfinishedExportJobs(r.Context())
+	if err != nil {
+		writeInternal(w, r, err, "failed to get export jobs")
+		return
+	}
+	deletions, err := h.st.GetUnfinishedAccountDeletions(r.Context())
+	if err != nil {
+		writeInternal(w, r, err, "failed to get account deletions")
+		return
+	}
+
+	jobs := make([]adminExportJob, 0, len(exports))
+	for _, j := range exports {
+		jobs = append(jobs, adminExportJob{Login: j.Login, ExportJob: j})
+	}
+	if deletions == nil {
+		deletions = []storage.AccountDeletion{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"exports": jobs, "accountDeletions": deletions})
+}
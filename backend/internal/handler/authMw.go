@@ -2,13 +2,31 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"net/http"
-)
 
+	"voicebook/internal/logging"
+	"voicebook/internal/storage"
+)
 
+// checkSession принимает access-токен из Authorization: Bearer или, как раньше,
+// X-Session-Id. Неверный Bearer не откатывается к сессии. Access-токен проверяется
+// только подписью: отключённый или удаляемый аккаунт теряет доступ, когда истечёт
+// короткий срок токена, а новый RefreshToken ему уже не выдаст.
 func (h *Handler) checkSession(r *http.Request) (string, bool) {
+	if tok, ok := bearerToken(r); ok {
+		if h.auth.Tokens == nil {
+			return "", false
+		}
+		claims, err := h.auth.Tokens.Verify(tok)
+		if err != nil {
+			return "", false
+		}
+		return claims.Subject, true
+	}
+
 	sessionID := r.Header.Get("X-Session-Id")
-	login, err := h.st.GetLoginBySession(sessionID)
+	login, err := h.st.GetLoginBySession(r.Context(), sessionID)
 	if err != nil || login == "" {
 		return "", false
 	}
@@ -16,16 +34,37 @@ func (h *Handler) checkSession(r *http.Request) (string, bool) {
 }
 
 func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login, ok := h.checkSession(r)
+		if !ok {
+			writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "forbidden, unauthorized")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "login", login)
+		ctx = logging.SetLogin(ctx, login)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-        login, ok := h.checkSession(r)
-        if !ok {
-            http.Error(w, "forbidden, unauthorized", http.StatusUnauthorized)
-            return
-        }
+// AdminMiddleware ставится после AuthMiddleware и пускает только администраторов.
+// Роль читается из базы на каждый запрос, поэтому снятие роли действует сразу,
+// даже для уже выданных access-токенов.
+func (h *Handler) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := r.Context().Value("login").(string)
 
-        ctx := context.WithValue(r.Context(), "login", login)
+		role, disabled, err := h.st.GetUserAccess(r.Context(), login)
+		if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+			writeInternal(w, r, err, "failed to check role")
+			return
+		}
+		if err != nil || disabled || role != storage.RoleAdmin {
+			writeError(w, r, http.StatusForbidden, codeForbidden, "admin role required")
+			return
+		}
 
-        next.ServeHTTP(w, r.WithContext(ctx))
-    })
+		next.ServeHTTP(w, r)
+	})
 }
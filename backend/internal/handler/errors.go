@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"voicebook/internal/logging"
+	"voicebook/internal/storage"
+	"voicebook/internal/validate"
+)
+
+// Коды ошибок API — стабильные строки, на которые может опираться клиент
+const (
+	codeInvalidRequest   = "invalid_request"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeNotAcceptable    = "not_acceptable"
+	codeValidation       = "validation_failed"
+	codeTooLarge         = "payload_too_large"
+	codeRateLimited      = "rate_limited"
+	codeQuotaExceeded    = "quota_exceeded"
+	codeConflict         = "conflict"
+	codeNotReady         = "not_ready"
+	codeUpstream         = "upstream_unavailable"
+	codeInternal         = "internal_error"
+)
+
+type errorBody struct {
+	Code      string                `json:"code"`
+	Message   string                `json:"message"`
+	RequestID string                `json:"requestId,omitempty"`
+	Fields    []validate.FieldError `json:"fields,omitempty"`
+	// Quota — какой лимит исчерпан (для quota_exceeded)
+	Quota string `json:"quota,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError отдаёт ошибку в едином формате {"error": {...}}
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	body := errorBody{Code: code, Message: message}
+	if req := logging.RequestFromContext(r.Context()); req != nil {
+		body.RequestID = req.ID
+	}
+	writeJSON(w, status, map[string]errorBody{"error": body})
+}
+
+// writeValidationError перечисляет все поля, не прошедшие проверку (422)
+func writeValidationError(w http.ResponseWriter, r *http.Request, errs validate.Errors) {
+	body := errorBody{Code: codeValidation, Message: "validation failed", Fields: errs}
+	if req := logging.RequestFromContext(r.Context()); req != nil {
+		body.RequestID = req.ID
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]errorBody{"error": body})
+}
+
+// writeQuotaError сообщает, какой лимит пользователя исчерпан
+func writeQuotaError(w http.ResponseWriter, r *http.Request, status int, quota, message string) {
+	body := errorBody{Code: codeQuotaExceeded, Message: message, Quota: quota}
+	if req := logging.RequestFromContext(r.Context()); req != nil {
+		body.RequestID = req.ID
+	}
+	writeJSON(w, status, map[string]errorBody{"error": body})
+}
+
+// writeInternal логирует причину, а клиенту отдаёт только общее сообщение
+func writeInternal(w http.ResponseWriter, r *http.Request, err error, message string) {
+	logging.FromContext(r.Context()).Error(message, "error", err)
+	writeError(w, r, http.StatusInternalServerError, codeInternal, message)
+}
+
+// writeStorageError — единственное место, где ошибки хранилища превращаются в HTTP-статусы
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, storage.ErrConflict):
+		writeError(w, r, http.StatusConflict, codeConflict, err.Error())
+	case errors.Is(err, storage.ErrForbidden):
+		writeError(w, r, http.StatusForbidden, codeForbidden, err.Error())
+	default:
+		writeInternal(w, r, err, message)
+	}
+}
+
+// NotFound и MethodNotAllowed — ответы роутера для несуществующих маршрутов
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, codeNotFound, "route not found")
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed")
+}
+
+// TooManyRequests — ответ ограничителя частоты; Retry-After ограничитель ставит сам
+func TooManyRequests(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusTooManyRequests, codeRateLimited, "too many requests, retry later")
+}
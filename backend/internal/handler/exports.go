@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"voicebook/internal/export"
+	"voicebook/internal/logging"
+	"voicebook/internal/storage"
+	"voicebook/internal/transcode"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *Handler) GetChapters(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
+		return
+	}
+
+	book, err := h.st.GetBook(r.Context(), bookID, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return
+	}
+	pages, err := h.st.GetBookPages(r.Context(), bookID)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get pages")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"chapters": export.Chapters(pages, book.Title)})
+}
+
+// PostExport ставит в очередь сборку книги (или одной главы) в один аудиофайл
+func (h *Handler) PostExport(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
+		return
+	}
+
+	var req struct {
+		Format  string `json:"format"`
+		Chapter *int   `json:"chapter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	if req.Format == "" {
+		req.Format = transcode.MP3
+	}
+	format, ok := export.ParseFormat(req.Format)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "unknown format")
+		return
+	}
+
+	book, err := h.st.GetBook(r.Context(), bookID, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return
+	}
+	pages, err := h.st.GetBookPages(r.Context(), bookID)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get pages")
+		return
+	}
+	_, _, err = export.Select(pages, book.Title, req.Chapter)
+	if errors.Is(err, export.ErrChapterNotFound) {
+		writeError(w, r, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	}
+	// лимит синтеза списывается по ходу сборки, только за страницы, которых нет в кеше TTS:
+	// книгу, которую уже прослушали, можно собрать и после исчерпания лимита
+	if !h.checkExportQuota(w, r, login) {
+		return
+	}
+
+	job, created, err := h.st.CreateExportJob(r.Context(), login, bookID, req.Chapter, format)
+	if err != nil {
+		writeInternal(w, r, err, "failed to create export")
+		return
+	}
+	if created {
+		h.ex.Enqueue(job)
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]any{"export": job})
+}
+
+func (h *Handler) GetExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.exportFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"export": job})
+}
+
+func (h *Handler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.exportFromURL(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != storage.ExportDone {
+		writeError(w, r, http.StatusConflict, codeNotReady, "export is not ready")
+		return
+	}
+
+	body, size, err := h.cl.OpenFile(r.Context(), job.FileURL)
+	if err != nil {
+		writeInternal(w, r, err, "failed to download export")
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", transcode.ContentTypes[job.Format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="book-%d.%s"`, job.BookID, transcode.Extensions[job.Format]))
+	if size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	io.Copy(w, body)
+}
+
+// DeleteExport удаляет собранный или упавший экспорт вместе с файлом, освобождая место
+func (h *Handler) DeleteExport(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	exportID, err := strconv.ParseInt(chi.URLParam(r, "exportId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid exportId")
+		return
+	}
+
+	job, err := h.st.DeleteExportJob(r.Context(), exportID, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to delete export")
+		return
+	}
+	if job.FileURL != "" {
+		if err := h.cl.DeleteObject(r.Context(), job.FileURL); err != nil {
+			logging.FromContext(r.Context()).Error("failed to delete export file", "export_id", job.ID, "url", job.FileURL, "error", err)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
+}
+
+func (h *Handler) exportFromURL(w http.ResponseWriter, r *http.Request) (storage.ExportJob, bool) {
+	login := r.Context().Value("login").(string)
+	exportID, err := strconv.ParseInt(chi.URLParam(r, "exportId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid exportId")
+		return storage.ExportJob{}, false
+	}
+
+	job, err := h.st.GetExportJob(r.Context(), exportID, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get export")
+		return storage.ExportJob{}, false
+	}
+	return job, true
+}
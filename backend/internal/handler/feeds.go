@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"voicebook/internal/export"
+	"voicebook/internal/logging"
+	"voicebook/internal/storage"
+	"voicebook/internal/transcode"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// эпизоды фида — главы, собранные экспортом в MP3
+const feedFormat = transcode.MP3
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PostFeedToken выпускает новый токен фидов; прежний перестаёт работать.
+// Токен показывается только в ответе — в базе хранится его хеш.
+func (h *Handler) PostFeedToken(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		writeInternal(w, r, err, "failed to generate token")
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	if err := h.st.ReplaceFeedToken(r.Context(), login, hashFeedToken(token)); err != nil {
+		writeInternal(w, r, err, "failed to save token")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"token":   token,
+		"feedUrl": baseURL(r) + "/feeds/" + token + "/book/{bookId}.xml",
+	})
+}
+
+func (h *Handler) DeleteFeedToken(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+
+	if err := h.st.RevokeFeedTokens(r.Context(), login); err != nil {
+		writeInternal(w, r, err, "failed to revoke token")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FeedAuthMiddleware пускает по токену из пути вместо X-Session-Id: подкаст-приложения не умеют заголовки
+func (h *Handler) FeedAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login, err := h.st.GetLoginByFeedToken(r.Context(), hashFeedToken(chi.URLParam(r, "token")))
+		if err != nil || login == "" {
+			writeError(w, r, http.StatusNotFound, codeNotFound, "feed not found")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "login", login)
+		ctx = logging.SetLogin(ctx, login)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Itunes  string     `xml:"xmlns:itunes,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Language    string    `xml:"language,omitempty"`
+	Author      string    `xml:"itunes:author,omitempty"`
+	Summary     string    `xml:"itunes:summary"`
+	Explicit    string    `xml:"itunes:explicit"`
+	Block       string    `xml:"itunes:block"`
+	Type        string    `xml:"itunes:type"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title     string       `xml:"title"`
+	GUID      rssGUID      `xml:"guid"`
+	PubDate   string       `xml:"pubDate"`
+	Enclosure rssEnclosure `xml:"enclosure"`
+	Duration  string       `xml:"itunes:duration"`
+	Episode   int          `xml:"itunes:episode"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// feedEpisode — глава книги и задача экспорта, которая собирает её эпизод;
+// Export — nil, если глава ещё не ставилась в очередь
+type feedEpisode struct {
+	Chapter export.Chapter     `json:"chapter"`
+	Export  *storage.ExportJob `json:"export"`
+}
+
+// bookFeed — книга, её страницы и последние задачи экспорта глав для фида
+type bookFeed struct {
+	book    storage.Book
+	pages   []storage.Page
+	exports map[int]storage.ExportJob
+}
+
+// loadBookFeed читает книгу из URL и состояние её эпизодов; при ошибке сам пишет ответ
+func (h *Handler) loadBookFeed(w http.ResponseWriter, r *http.Request) (bookFeed, bool) {
+	login := r.Context().Value("login").(string)
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
+		return bookFeed{}, false
+	}
+
+	var f bookFeed
+	f.book, err = h.st.GetBook(r.Context(), bookID, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return bookFeed{}, false
+	}
+	f.pages, err = h.st.GetBookPages(r.Context(), bookID)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get pages")
+		return bookFeed{}, false
+	}
+	f.exports, err = h.st.GetChapterExports(r.Context(), login, bookID, feedFormat)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get exports")
+		return bookFeed{}, false
+	}
+	return f, true
+}
+
+func (f bookFeed) episodes() []feedEpisode {
+	var episodes []feedEpisode
+	for _, ch := range export.Chapters(f.pages, f.book.Title) {
+		ep := feedEpisode{Chapter: ch}
+		if job, ok := f.exports[ch.Index]; ok {
+			ep.Export = &job
+		}
+		episodes = append(episodes, ep)
+	}
+	return episodes
+}
+
+// GetFeedEpisodes — главы книги и состояние их эпизодов в фиде, в том числе ошибки сборки
+func (h *Handler) GetFeedEpisodes(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.loadBookFeed(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"episodes": f.episodes()})
+}
+
+// PostFeedEpisodes ставит в очередь сборку эпизодов фида: глав, которые ещё не собирались
+// или чья сборка упала. Повторный запрос
+// не создаёт вторых задач для глав, которые уже в очереди.
+func (h *Handler) PostFeedEpisodes(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	f, ok := h.loadBookFeed(w, r)
+	if !ok {
+		return
+	}
+
+	var todo []int
+	for _, ch := range export.Chapters(f.pages, f.book.Title) {
+		if job, ok := f.exports[ch.Index]; ok && job.Status != storage.ExportFailed {
+			continue
+		}
+		todo = append(todo, ch.Index)
+	}
+	// лимит синтеза списывается при сборке, только за страницы, которых нет в кеше TTS
+	if len(todo) > 0 && !h.checkExportQuota(w, r, login) {
+		return
+	}
+
+	for _, chapter := range todo {
+		job, created, err := h.st.CreateExportJob(r.Context(), login, f.book.BookID, &chapter, feedFormat)
+		if err != nil {
+			writeInternal(w, r, err, "failed to create export")
+			return
+		}
+		if created {
+			h.ex.Enqueue(job)
+		}
+		f.exports[chapter] = job
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]any{"episodes": f.episodes()})
+}
+
+// GetBookFeed отдаёт RSS книги: по эпизоду на собранную главу. Сам фид ничего не собирает —
+// эпизоды ставит в очередь PostFeedEpisodes, а подкаст-приложение лишь опрашивает фид.
+func (h *Handler) GetBookFeed(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.loadBookFeed(w, r)
+	if !ok {
+		return
+	}
+	book, bookID := f.book, f.book.BookID
+
+	feedURL := baseURL(r) + "/feeds/" + chi.URLParam(r, "token")
+	channel := rssChannel{
+		Title:       book.Title,
+		Link:        feedURL + "/book/" + strconv.FormatInt(bookID, 10) + ".xml",
+		Description: book.Title,
+		Author:      book.Author,
+		Summary:     book.Title,
+		Explicit:    "false",
+		Block:       "Yes",
+		Type:        "serial",
+	}
+
+	for _, ep := range f.episodes() {
+		ch, job := ep.Chapter, ep.Export
+		if job == nil || job.Status != storage.ExportDone {
+			continue
+		}
+
+		channel.Items = append(channel.Items, rssItem{
+			Title:   ch.Title,
+			GUID:    rssGUID{IsPermaLink: "false", Value: fmt.Sprintf("book-%d-export-%d", bookID, job.ID)},
+			PubDate: time.Unix(job.UpdatedTs, 0).UTC().Format(time.RFC1123Z),
+			Enclosure: rssEnclosure{
+				URL:    fmt.Sprintf("%s/book/%d/chapter/%d.mp3", feedURL, bookID, ch.Index),
+				Length: job.SizeBytes,
+				Type:   transcode.ContentTypes[feedFormat],
+			},
+			Duration: formatDuration(job.DurationMs),
+			Episode:  ch.Index,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(rssFeed{
+		Version: "2.0",
+		Itunes:  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		Channel: channel,
+	})
+}
+
+func (h *Handler) GetFeedEpisode(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
+		return
+	}
+	chapter, err := strconv.Atoi(chi.URLParam(r, "chapter"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid chapter")
+		return
+	}
+
+	exports, err := h.st.GetChapterExports(r.Context(), login, bookID, feedFormat)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get exports")
+		return
+	}
+	job, ok := exports[chapter]
+	if !ok || job.Status != storage.ExportDone {
+		writeError(w, r, http.StatusNotFound, codeNotFound, "episode not found")
+		return
+	}
+
+	body, size, err := h.cl.OpenFile(r.Context(), job.FileURL)
+	if err != nil {
+		writeInternal(w, r, err, "failed to download episode")
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", transcode.ContentTypes[feedFormat])
+	if size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	io.Copy(w, body)
+}
+
+// baseURL — адрес сервера, как его видит клиент (с учётом прокси)
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	host := r.Host
+	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+	return scheme + "://" + host
+}
+
+func formatDuration(ms int64) string {
+	s := ms / 1000
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
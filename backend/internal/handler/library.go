@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"voicebook/internal/logging"
+	"voicebook/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type publishRequest struct {
+	BookID int64 `json:"bookId"`
+}
+
+// LibraryMiddleware скрывает маршруты каталога, если он выключен
+func (h *Handler) LibraryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.library {
+			NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// addLibraryCopy добавляет в коллекцию книгу каталога с тем же текстом, что у загружаемой.
+// true — такая книга нашлась или случилась ошибка, ответ уже записан.
+func (h *Handler) addLibraryCopy(w http.ResponseWriter, r *http.Request, login, text string) bool {
+	if !h.library {
+		return false
+	}
+	bookID, err := h.st.FindLibraryBook(r.Context(), storage.ContentHash(text))
+	if errors.Is(err, storage.ErrBookNotFound) {
+		return false
+	}
+	if err != nil {
+		writeInternal(w, r, err, "failed to search library")
+		return true
+	}
+
+	book, err := h.st.AddLibraryRef(r.Context(), login, bookID)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to add book from library")
+		return true
+	}
+	logging.FromContext(r.Context()).Info("upload replaced with library book", "book_id", bookID)
+	writeJSON(w, http.StatusOK, map[string]any{"book": book})
+	return true
+}
+
+// GetLibrary — книги каталога; added: true у тех, что уже в коллекции
+func (h *Handler) GetLibrary(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+
+	books, err := h.st.GetLibrary(r.Context(), login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get library")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"books": books})
+}
+
+// PostLibraryBook добавляет книгу каталога в коллекцию. Убирается она из коллекции
+// обычным DELETE /api/book/{bookId}/.
+func (h *Handler) PostLibraryBook(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
+		return
+	}
+
+	book, err := h.st.AddLibraryRef(r.Context(), login, bookID)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to add book from library")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"book": book})
+}
+
+// AdminPublishBook переносит книгу в каталог. Прежний владелец оставляет её в коллекции
+// по ссылке, а место она у него больше не занимает. Снять книгу с публикации —
+// DELETE /api/admin/books/{bookId}/: она удаляется из всех коллекций.
+func (h *Handler) AdminPublishBook(w http.ResponseWriter, r *http.Request) {
+	var req publishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.BookID == 0 {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "bookId is required")
+		return
+	}
+
+	book, err := h.st.PublishBook(r.Context(), req.BookID)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to publish book")
+		return
+	}
+	logging.FromContext(r.Context()).Info("book published to library", "book_id", book.BookID)
+
+	writeJSON(w, http.StatusOK, map[string]any{"book": book})
+}
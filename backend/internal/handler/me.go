@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"voicebook/internal/logging"
+	"voicebook/internal/storage"
+)
+
+type exportedBook struct {
+	BookID     int64  `json:"bookId"`
+	Title      string `json:"title"`
+	Author     string `json:"author"`
+	UploadedTs int64  `json:"uploadedTs"`
+	// путь к исходному файлу внутри архива
+	SourceFile string `json:"sourceFile,omitempty"`
+}
+
+type exportedSettings struct {
+	Voice storage.VoiceSettings               `json:"voice"`
+	Books map[int64]storage.BookVoiceSettings `json:"books"`
+}
+
+// ExportAccount отдаёт ZIP со всеми данными пользователя: аккаунт, метаданные
+// и исходные файлы книг, прогресс и настройки. Всё, что берётся из базы,
+// читается до начала ответа, чтобы ошибку ещё можно было вернуть статусом.
+func (h *Handler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	ctx := r.Context()
+
+	email, err := h.st.GetUserEmail(ctx, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get user")
+		return
+	}
+	books, err := h.st.GetUserBooks(ctx, login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get books")
+		return
+	}
+	progress, err := h.st.GetAllUserProgress(ctx, login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get progress")
+		return
+	}
+	var settings exportedSettings
+	if settings.Voice, err = h.st.GetUserSettings(ctx, login); err != nil {
+		writeInternal(w, r, err, "failed to get settings")
+		return
+	}
+	if settings.Books, err = h.st.GetAllBookSettings(ctx, login); err != nil {
+		writeInternal(w, r, err, "failed to get book settings")
+		return
+	}
+
+	meta := make([]exportedBook, 0, len(books))
+	for _, b := range books {
+		eb := exportedBook{BookID: b.BookID, Title: b.Title, Author: b.Author, UploadedTs: b.UploadedTs}
+		if b.BookUrl != "" {
+			eb.SourceFile = fmt.Sprintf("books/%d/%s", b.BookID, path.Base(b.BookUrl))
+		}
+		meta = append(meta, eb)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="voicebook-%s-%s.zip"`, login, time.Now().Format("20060102")))
+
+	// после первого байта статус уже не поменять: при ошибке архив обрывается,
+	// и клиент не сможет его открыть
+	zw := zip.NewWriter(w)
+	if err := h.writeAccountArchive(r, zw, login, email, books, meta, progress, settings); err != nil {
+		logging.FromContext(ctx).Error("account export interrupted", "error", err)
+		return
+	}
+	if err := zw.Close(); err != nil {
+		logging.FromContext(ctx).Error("account export interrupted", "error", err)
+	}
+}
+
+func (h *Handler) writeAccountArchive(r *http.Request, zw *zip.Writer, login, email string, books []storage.Book, meta []exportedBook, progress []storage.UserProgress, settings exportedSettings) error {
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"account.json", map[string]string{"login": login, "email": email}},
+		{"books.json", meta},
+		{"progress.json", progress},
+		{"settings.json", settings},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.v); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+
+	for i, b := range books {
+		if meta[i].SourceFile == "" {
+			continue
+		}
+		if err := h.copySource(r, zw, meta[i].SourceFile, b.BookUrl); err != nil {
+			return fmt.Errorf("write source of book %d: %w", b.BookID, err)
+		}
+	}
+	return nil
+}
+
+func (h *Handler) copySource(r *http.Request, zw *zip.Writer, name, url string) error {
+	body, _, err := h.cl.OpenFile(r.Context(), url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, body)
+	return err
+}
+
+// DeleteAccount закрывает доступ к аккаунту сразу, а сами данные удаляет в фоне.
+// Уже выданные access-токены отозвать нельзя: они действуют до истечения auth.accessTTL.
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+
+	d, err := h.st.StartAccountDeletion(r.Context(), login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to start account deletion")
+		return
+	}
+	h.del.Enqueue(login)
+	logging.FromContext(r.Context()).Info("account deletion requested")
+
+	writeJSON(w, http.StatusAccepted, d)
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"voicebook/internal/logging"
+	"voicebook/internal/oidc"
+	"voicebook/internal/storage"
+	"voicebook/internal/validate"
+)
+
+// сколько ждём возвращения пользователя со страницы провайдера
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCLogin начинает вход через провайдера: запоминает state, nonce и PKCE verifier
+// и отправляет браузер на страницу входа
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.auth.OIDC == nil {
+		writeError(w, r, http.StatusNotFound, codeNotFound, "sso login is disabled")
+		return
+	}
+
+	state, nonce := randomString(), randomString()
+	verifier := oidc.NewVerifier()
+	if err := h.st.SaveOIDCState(r.Context(), state, nonce, verifier, time.Now().Add(oidcStateTTL)); err != nil {
+		writeInternal(w, r, err, "failed to start sso login")
+		return
+	}
+
+	http.Redirect(w, r, h.auth.OIDC.AuthURL(state, nonce, verifier), http.StatusFound)
+}
+
+// OIDCCallback завершает вход: проверяет ответ провайдера, находит или заводит
+// пользователя и возвращает браузер на фронтенд с данными сессии во фрагменте URL
+// (фрагмент не уходит на сервер и не попадает в логи)
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.auth.OIDC == nil {
+		writeError(w, r, http.StatusNotFound, codeNotFound, "sso login is disabled")
+		return
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "sso login failed: "+e)
+		return
+	}
+
+	nonce, verifier, err := h.st.TakeOIDCState(r.Context(), q.Get("state"))
+	if errors.Is(err, storage.ErrOIDCStateNotFound) {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "sso login expired or was not started, try again")
+		return
+	}
+	if err != nil {
+		writeInternal(w, r, err, "failed to check sso login state")
+		return
+	}
+
+	id, err := h.auth.OIDC.Exchange(r.Context(), q.Get("code"), nonce, verifier)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("sso login rejected", "error", err)
+		writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "sso login failed")
+		return
+	}
+
+	login, err := h.resolveIdentity(r, id)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to resolve sso user")
+		return
+	}
+
+	resp, err := h.newSession(r, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to start session")
+		return
+	}
+
+	frag := url.Values{"sessionId": {resp.SessionID}}
+	if resp.AccessToken != "" {
+		frag.Set("accessToken", resp.AccessToken)
+		frag.Set("refreshToken", resp.RefreshToken)
+		frag.Set("tokenType", resp.TokenType)
+		frag.Set("expiresIn", strconv.FormatInt(resp.ExpiresIn, 10))
+	}
+	http.Redirect(w, r, h.auth.PostLoginURL+"#"+frag.Encode(), http.StatusFound)
+}
+
+// resolveIdentity ищет пользователя по внешней учётной записи, затем по
+// подтверждённому email (и привязывает запись), а иначе заводит нового
+func (h *Handler) resolveIdentity(r *http.Request, id oidc.Identity) (string, error) {
+	ctx := r.Context()
+
+	login, err := h.st.GetLoginByIdentity(ctx, id.Issuer, id.Subject)
+	if !errors.Is(err, storage.ErrIdentityNotFound) {
+		return login, err
+	}
+
+	email := ""
+	if id.EmailVerified {
+		email = id.Email
+	}
+	if email != "" {
+		login, err := h.st.GetLoginByEmail(ctx, email)
+		switch {
+		case err == nil:
+			return login, h.st.LinkIdentity(ctx, id.Issuer, id.Subject, login, email)
+		case !errors.Is(err, storage.ErrUserNotFound):
+			return "", err
+		}
+	}
+
+	// пароль случайный: такой пользователь входит только через провайдера
+	password := randomString()
+	base := loginBase(id)
+	for attempt := 1; ; attempt++ {
+		login := base
+		switch {
+		case attempt > 10:
+			login = base + "-" + randomString()[:6]
+		case attempt > 1:
+			login = base + "-" + strconv.Itoa(attempt)
+		}
+
+		err := h.st.CreateUserWithIdentity(ctx, login, password, email, id.Issuer, id.Subject)
+		if errors.Is(err, storage.ErrUserExists) && attempt <= 10 {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		logging.FromContext(ctx).Info("user created from sso login", "created_login", login, "issuer", id.Issuer)
+		return login, nil
+	}
+}
+
+// loginBase подбирает логин из preferred_username или email по правилам validate.Login,
+// оставляя место для суффикса
+func loginBase(id oidc.Identity) string {
+	src := id.Username
+	if src == "" {
+		src, _, _ = strings.Cut(id.Email, "@")
+	}
+
+	var b strings.Builder
+	for _, c := range src {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			b.WriteRune(c)
+		case (c == '_' || c == '.' || c == '-') && b.Len() > 0:
+			b.WriteRune(c)
+		}
+	}
+	login := b.String()
+	if len(login) > validate.LoginMaxLen-8 {
+		login = login[:validate.LoginMaxLen-8]
+	}
+	if validate.Login(login) != "" {
+		return "user"
+	}
+	return login
+}
+
+func randomString() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -2,11 +2,22 @@ package handler
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"voicebook/internal/export"
+	"voicebook/internal/metrics"
+	"voicebook/internal/storage"
+	"voicebook/internal/timing"
+	"voicebook/internal/transcode"
+	"voicebook/internal/tts"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -14,31 +25,35 @@ import (
 func (h *Handler) GetCurrentPage(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("login").(string)
 	bookID, _ := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if _, err := h.st.GetBook(r.Context(), bookID, login); err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return
+	}
 
 	// получаем прогресс (последнюю завершённую страницу)
-	lastFinished, err := h.st.GetUserProgress(login, bookID)
-	if err != nil && err != sql.ErrNoRows {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	lastFinished, err := h.st.GetUserProgress(r.Context(), login, bookID)
+	if err != nil && !errors.Is(err, storage.ErrProgressNotFound) {
+		writeInternal(w, r, err, "internal error")
 		return
 	}
 
 	var current int64
 	if lastFinished == 0 {
 		// ещё не читал → первая страница
-		firstPage, err := h.st.GetNextPage(bookID, 0)
+		firstPage, err := h.st.GetNextPage(r.Context(), bookID, 0)
 		if err != nil || firstPage == nil {
-			http.Error(w, "book has no pages", http.StatusNotFound)
+			writeError(w, r, http.StatusNotFound, codeNotFound, "book has no pages")
 			return
 		}
 		current = *firstPage
 		// сразу сохраняем прогресс
-		if err := h.st.SetUserProgress(login, bookID, current-1); err != nil {
-			http.Error(w, "cannot set initial progress", http.StatusInternalServerError)
+		if err := h.st.SetUserProgress(r.Context(), login, bookID, current-1); err != nil {
+			writeInternal(w, r, err, "cannot set initial progress")
 			return
 		}
 	} else {
 		// текущая страница = следующая после последней завершённой
-		nextPage, _ := h.st.GetNextPage(bookID, lastFinished)
+		nextPage, _ := h.st.GetNextPage(r.Context(), bookID, lastFinished)
 		if nextPage == nil {
 			// последняя страница уже прочитана
 			current = lastFinished
@@ -47,20 +62,16 @@ func (h *Handler) GetCurrentPage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	prev, _ := h.st.GetPrevPage(bookID, current)
-	next, _ := h.st.GetNextPage(bookID, current)
+	prev, _ := h.st.GetPrevPage(r.Context(), bookID, current)
+	next, _ := h.st.GetNextPage(r.Context(), bookID, current)
 
-	json.NewEncoder(w).Encode(map[string]any{
+	writeJSON(w, http.StatusOK, map[string]any{
 		"pageId":         current,
 		"previousPageId": prev,
 		"nextPageId":     next,
 	})
 }
 
-
-
-
-
 func (h *Handler) FinishedPage(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		BookID int64 `json:"bookId"`
@@ -68,131 +79,279 @@ func (h *Handler) FinishedPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
 		return
 	}
 
 	login := r.Context().Value("login").(string)
 
+	// прогресс у каждого читателя свой, но читать книгу он должен иметь право
+	if _, err := h.st.GetBook(r.Context(), req.BookID, login); err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return
+	}
+
 	// проверяем существование страницы
-	exists, err := h.st.PageExists(req.BookID, req.PageID)
+	exists, err := h.st.PageExists(r.Context(), req.BookID, req.PageID)
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeInternal(w, r, err, "internal error")
 		return
 	}
 	if !exists {
-		http.Error(w, "page does not exist", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "page does not exist")
 		return
 	}
 
-	if err := h.st.SetUserProgress(login, req.BookID, req.PageID); err != nil {
-		http.Error(w, "cannot save progress", http.StatusInternalServerError)
+	if err := h.st.SetUserProgress(r.Context(), login, req.BookID, req.PageID); err != nil {
+		writeInternal(w, r, err, "cannot save progress")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
 }
 
-
 func (h *Handler) GetPageText(w http.ResponseWriter, r *http.Request) {
-
+	login := r.Context().Value("login").(string)
 	bookIDStr := chi.URLParam(r, "bookId")
 	pageIDStr := chi.URLParam(r, "pageId")
 
 	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid bookId", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
 		return
 	}
 
 	pageID, err := strconv.Atoi(pageIDStr)
 	if err != nil {
-		http.Error(w, "invalid pageId", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid pageId")
 		return
 	}
 
-	page, err := h.st.GetPage(bookID, int64(pageID))
+	if _, err := h.st.GetBook(r.Context(), bookID, login); err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return
+	}
+	page, err := h.st.GetPage(r.Context(), bookID, int64(pageID))
 	if err != nil {
-		http.Error(w, "page not found", http.StatusNotFound)
+		writeStorageError(w, r, err, "failed to get page")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{
+	writeJSON(w, http.StatusOK, map[string]string{
 		"text": page.Text,
 	})
 }
 
-func (h *Handler) GetPageAudio(w http.ResponseWriter, r *http.Request) {
-	bookIDStr := chi.URLParam(r, "bookId")
-	pageIDStr := chi.URLParam(r, "pageId")
+// synthesizePage озвучивает страницу из URL книги bookID с настройками settings
+// (формат в них — тот, в котором синтезирует TTS); при ошибке сам пишет ответ
+func (h *Handler) synthesizePage(w http.ResponseWriter, r *http.Request, bookID int64, settings storage.VoiceSettings) (storage.Page, tts.Result, bool) {
+	login := r.Context().Value("login").(string)
 
-	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
+	pageID, err := strconv.Atoi(chi.URLParam(r, "pageId"))
 	if err != nil {
-		http.Error(w, "invalid bookId", http.StatusBadRequest)
-		return
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid pageId")
+		return storage.Page{}, tts.Result{}, false
 	}
 
-	pageID, err := strconv.Atoi(pageIDStr)
+	book, err := h.st.GetBook(r.Context(), bookID, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return storage.Page{}, tts.Result{}, false
+	}
+	page, err := h.st.GetPage(r.Context(), bookID, int64(pageID))
 	if err != nil {
-		http.Error(w, "invalid pageId", http.StatusBadRequest)
+		writeStorageError(w, r, err, "failed to get page")
+		return storage.Page{}, tts.Result{}, false
+	}
+	heading, err := h.openingHeading(r.Context(), book, page)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get chapter")
+		return storage.Page{}, tts.Result{}, false
+	}
+
+	// озвученная страница лимит не расходует, поэтому сначала — кеш, без записи в базу
+	ttsResp, err := h.tts.Lookup(r.Context(), page.Text, heading, settings)
+	if errors.Is(err, tts.ErrNotCached) {
+		rsv, ok := h.reserveTTS(w, r, login, int64(utf8.RuneCountInString(page.Text)))
+		if !ok {
+			return storage.Page{}, tts.Result{}, false
+		}
+		ttsResp, err = h.tts.Synthesize(r.Context(), page.Text, heading, settings)
+		h.settleTTS(r, rsv, ttsResp, err)
+	}
+	if errors.Is(err, tts.ErrUnavailable) {
+		writeError(w, r, http.StatusBadGateway, codeUpstream, "tts service unavailable")
+		return storage.Page{}, tts.Result{}, false
+	}
+	if err != nil {
+		writeInternal(w, r, err, "tts generation failed")
+		return storage.Page{}, tts.Result{}, false
+	}
+
+	return page, ttsResp, true
+}
+
+// GetPageAudio отдаёт аудио страницы в формате из ?format= или Accept, по умолчанию —
+// из настроек озвучки. TTS синтезирует WAV, остальные форматы перекодируются здесь
+// и кешируются в S3 рядом с оригиналом.
+func (h *Handler) GetPageAudio(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
 		return
 	}
 
-	page, err := h.st.GetPage(bookID, int64(pageID))
+	settings, err := h.st.GetEffectiveSettings(r.Context(), login, bookID)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get voice settings")
+		return
+	}
+	format, err := transcode.Negotiate(r.URL.Query().Get("format"), r.Header.Get("Accept"), settings.Format)
 	if err != nil {
-		http.Error(w, "page not found", http.StatusNotFound)
+		writeError(w, r, http.StatusNotAcceptable, codeNotAcceptable, err.Error())
 		return
 	}
 
-	// Формируем JSON для TTS
-	body, _ := json.Marshal(map[string]string{
-		"text": page.Text,
-	})
+	// без ffmpeg нужный формат сразу синтезирует TTS-сервис
+	settings.Format = format
+	if h.enc != nil {
+		settings.Format = transcode.WAV
+	}
+	_, ttsResp, ok := h.synthesizePage(w, r, bookID, settings)
+	if !ok {
+		return
+	}
 
-	// Отправка запроса к TTS
-	req, err := http.NewRequest(
-		http.MethodPost,
-		"http://158.160.73.166:8000/api/v1/tts",
-		bytes.NewBuffer(body),
-	)
+	audioBytes, err := h.audioVariant(r.Context(), ttsResp.FileURL, settings.Format, format)
 	if err != nil {
-		http.Error(w, "failed to create tts request", http.StatusInternalServerError)
+		writeInternal(w, r, err, "failed to get audio")
 		return
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 20 * time.Second}
-	resp, err := client.Do(req)
+	w.Header().Set("Content-Type", transcode.ContentTypes[format])
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(audioBytes))
+}
+
+// audioVariant возвращает аудио в формате format, перекодируя и кешируя его при необходимости
+func (h *Handler) audioVariant(ctx context.Context, fileURL, from, format string) ([]byte, error) {
+	if from == format {
+		return h.cl.DownloadFile(ctx, fileURL)
+	}
+
+	name := path.Base(fileURL)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	variantURL := h.cl.URL("variants/" + name + "." + transcode.Extensions[format])
+
+	exists, err := h.cl.Exists(ctx, variantURL)
 	if err != nil {
-		http.Error(w, "tts service unavailable", http.StatusBadGateway)
-		return
+		return nil, err
+	}
+	metrics.AudioCache("variant", exists)
+	if exists {
+		return h.cl.DownloadFile(ctx, variantURL)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		http.Error(w, "tts generation failed", http.StatusInternalServerError)
+	wav, err := h.cl.DownloadFile(ctx, fileURL)
+	if err != nil {
+		return nil, err
+	}
+	data, err := h.enc.Transcode(ctx, wav, format)
+	if err != nil {
+		return nil, err
+	}
+	key := "variants/" + name + "." + transcode.Extensions[format]
+	if _, err := h.cl.PutObject(ctx, key, bytes.NewReader(data), transcode.ContentTypes[format]); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetPageTimings возвращает время начала и конца каждого предложения и слова страницы.
+// start/end — смещения в символах текста из GetPageText. Время берётся из метаданных
+// уже озвученной страницы: сам запрос ничего не синтезирует и квоту не тратит.
+// Формат выбирается так же, как у GetPageAudio, но только по ?format=.
+func (h *Handler) GetPageTimings(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
+		return
+	}
+	pageID, err := strconv.ParseInt(chi.URLParam(r, "pageId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid pageId")
 		return
 	}
 
-	// Декодируем ответ TTS
-	var ttsResp struct {
-		Source  string `json:"source"`
-		FileURL string `json:"file_url"`
+	book, err := h.st.GetBook(r.Context(), bookID, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return
+	}
+	page, err := h.st.GetPage(r.Context(), bookID, pageID)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get page")
+		return
+	}
+	heading, err := h.openingHeading(r.Context(), book, page)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get chapter")
+		return
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&ttsResp); err != nil {
-		http.Error(w, "invalid tts response", http.StatusInternalServerError)
+	settings, err := h.st.GetEffectiveSettings(r.Context(), login, bookID)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get voice settings")
 		return
 	}
 
-	// Скачиваем аудио с S3
-	audioBytes, err := h.cl.DownloadFile(r.Context(), ttsResp.FileURL)
+	// время одинаково для всех форматов; с ffmpeg GetPageAudio синтезирует WAV
+	settings.Format, err = transcode.Negotiate(r.URL.Query().Get("format"), "", settings.Format)
 	if err != nil {
-		http.Error(w, "failed to download audio", http.StatusInternalServerError)
+		writeError(w, r, http.StatusNotAcceptable, codeNotAcceptable, err.Error())
+		return
+	}
+	if h.enc != nil {
+		settings.Format = transcode.WAV
+	}
+	ttsResp, err := h.tts.Lookup(r.Context(), page.Text, heading, settings)
+	if errors.Is(err, tts.ErrNotCached) {
+		writeError(w, r, http.StatusNotFound, codeNotFound, "page audio is not synthesized yet")
+		return
+	}
+	if errors.Is(err, tts.ErrUnavailable) {
+		writeError(w, r, http.StatusBadGateway, codeUpstream, "tts service unavailable")
+		return
+	}
+	if err != nil {
+		writeInternal(w, r, err, "failed to look up page audio")
 		return
 	}
 
-	// Отдаём клиенту WAV
-	w.Header().Set("Content-Type", "audio/wav")
-	w.Write(audioBytes)
+	var meta timing.Meta
+	if ttsResp.MetaURL != "" {
+		data, err := h.cl.DownloadFile(r.Context(), ttsResp.MetaURL)
+		if err != nil {
+			writeInternal(w, r, err, "failed to download timings")
+			return
+		}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			writeInternal(w, r, err, "invalid timings")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"durationMs": meta.DurationMs,
+		"sentences":  timing.Marks(timing.Sentences(page.Text, heading), meta),
+	})
 }
 
+// openingHeading — заголовок главы, которую открывает страница (см. export.OpeningHeading)
+func (h *Handler) openingHeading(ctx context.Context, book storage.Book, page storage.Page) (string, error) {
+	prev, err := h.st.GetPrevPage(ctx, book.BookID, int64(page.PageIdx))
+	if err != nil {
+		return "", err
+	}
+	return export.OpeningHeading(page, prev == nil, book.Title), nil
+}
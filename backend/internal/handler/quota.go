@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"voicebook/internal/logging"
+	"voicebook/internal/storage"
+	"voicebook/internal/tts"
+)
+
+// Названия лимитов в ответе quota_exceeded
+const (
+	quotaBytes = "storage_bytes"
+	quotaBooks = "books"
+	quotaChars = "monthly_tts_chars"
+)
+
+// usageFor возвращает лимиты и текущее использование пользователя
+func (h *Handler) usageFor(r *http.Request, login string) (storage.Quota, storage.Usage, error) {
+	q, err := h.st.GetQuota(r.Context(), login, h.quota)
+	if err != nil {
+		return q, storage.Usage{}, err
+	}
+	u, err := h.st.GetUsage(r.Context(), login, storage.UsageMonth(time.Now()))
+	return q, u, err
+}
+
+// checkUploadQuota проверяет, поместится ли ещё одна книга размером size; при отказе сам пишет ответ
+func (h *Handler) checkUploadQuota(w http.ResponseWriter, r *http.Request, login string, size int64) bool {
+	q, u, err := h.usageFor(r, login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to check quota")
+		return false
+	}
+	if q.MaxBooks > 0 && u.Books >= q.MaxBooks {
+		writeQuotaError(w, r, http.StatusRequestEntityTooLarge, quotaBooks,
+			fmt.Sprintf("book limit reached: %d of %d books", u.Books, q.MaxBooks))
+		return false
+	}
+	if q.MaxBytes > 0 && u.StoredBytes+size > q.MaxBytes {
+		writeQuotaError(w, r, http.StatusRequestEntityTooLarge, quotaBytes,
+			fmt.Sprintf("storage quota exceeded: %d of %d bytes used, file is %d bytes", u.StoredBytes, q.MaxBytes, size))
+		return false
+	}
+	return true
+}
+
+// checkExportQuota не даёт собирать экспорт, когда место уже занято: размер файла
+// станет известен только после сборки. При отказе сам пишет ответ.
+func (h *Handler) checkExportQuota(w http.ResponseWriter, r *http.Request, login string) bool {
+	q, u, err := h.usageFor(r, login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to check quota")
+		return false
+	}
+	if q.MaxBytes > 0 && u.StoredBytes >= q.MaxBytes {
+		writeQuotaError(w, r, http.StatusRequestEntityTooLarge, quotaBytes,
+			fmt.Sprintf("storage quota exceeded: %d of %d bytes used, delete books or exports to free space", u.StoredBytes, q.MaxBytes))
+		return false
+	}
+	return true
+}
+
+// ttsReservation — символы, списанные из месячного лимита до синтеза страницы
+type ttsReservation struct {
+	login string
+	month string
+	chars int64
+}
+
+// reserveTTS списывает chars символов из месячного лимита до синтеза: проверка и списание —
+// один запрос, поэтому параллельные запросы не превышают лимит вместе. Вызывается после
+// промаха tts.Lookup, так что уже озвученные страницы доступны и после исчерпания лимита.
+// При отказе сам пишет ответ.
+func (h *Handler) reserveTTS(w http.ResponseWriter, r *http.Request, login string, chars int64) (ttsReservation, bool) {
+	rsv := ttsReservation{login: login, month: storage.UsageMonth(time.Now()), chars: chars}
+	q, err := h.st.GetQuota(r.Context(), login, h.quota)
+	if err != nil {
+		writeInternal(w, r, err, "failed to check quota")
+		return rsv, false
+	}
+	err = h.st.ReserveSynthesizedChars(r.Context(), login, rsv.month, chars, q.MonthlyChars)
+	if errors.Is(err, storage.ErrTTSQuotaExceeded) {
+		u, err := h.st.GetUsage(r.Context(), login, rsv.month)
+		if err != nil {
+			writeInternal(w, r, err, "failed to check quota")
+			return rsv, false
+		}
+		writeTTSQuotaError(w, r, q, u)
+		return rsv, false
+	}
+	if err != nil {
+		writeInternal(w, r, err, "failed to check quota")
+		return rsv, false
+	}
+	return rsv, true
+}
+
+// settleTTS возвращает в лимит списанные символы, если TTS взял страницу из кеша или
+// синтез не удался. Ошибку только логируем: ответ пользователю от неё не зависит.
+func (h *Handler) settleTTS(r *http.Request, rsv ttsReservation, res tts.Result, synthErr error) {
+	if synthErr == nil && res.Source != "cached" {
+		return
+	}
+	// клиент мог уже отключиться, а вернуть символы нужно всё равно
+	ctx := context.WithoutCancel(r.Context())
+	if err := h.st.AddSynthesizedChars(ctx, rsv.login, rsv.month, -rsv.chars); err != nil {
+		logging.FromContext(ctx).Error("failed to release tts usage", "error", err)
+	}
+}
+
+// writeTTSQuotaError отвечает 429 с Retry-After до начала следующего месяца
+func writeTTSQuotaError(w http.ResponseWriter, r *http.Request, q storage.Quota, u storage.Usage) {
+	now := time.Now().UTC()
+	next := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(next.Sub(now).Seconds())+1, 10))
+	writeQuotaError(w, r, http.StatusTooManyRequests, quotaChars,
+		fmt.Sprintf("monthly speech synthesis quota exceeded: %d of %d characters used", u.SynthesizedChars, q.MonthlyChars))
+}
+
+// GetUsage — занятое место, синтез за текущий месяц и лимиты пользователя
+func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+
+	q, u, err := h.usageFor(r, login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get usage")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"usage": u, "quota": q})
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"voicebook/internal/storage"
+	"voicebook/internal/transcode"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// голоса SpeechKit, которые поддерживает TTS-сервис
+var allowedVoices = map[string]bool{
+	"alena": true, "filipp": true, "ermil": true, "jane": true,
+	"madirus": true, "omazh": true, "zahar": true, "dasha": true,
+	"julia": true, "lera": true, "masha": true, "marina": true,
+	"alexander": true, "kirill": true, "anton": true, "john": true,
+}
+
+var allowedRoles = map[string]bool{
+	"neutral": true, "good": true, "evil": true, "strict": true,
+	"friendly": true, "whisper": true,
+}
+
+func validateVoiceSettings(vs storage.VoiceSettings) string {
+	if !allowedVoices[vs.Voice] {
+		return "unknown voice"
+	}
+	if !allowedRoles[vs.Role] {
+		return "unknown role"
+	}
+	if vs.Speed < 0.1 || vs.Speed > 3.0 {
+		return "speed must be between 0.1 and 3.0"
+	}
+	if vs.PitchShift < -1000 || vs.PitchShift > 1000 {
+		return "pitchShift must be between -1000 and 1000"
+	}
+	if _, ok := transcode.ContentTypes[vs.Format]; !ok {
+		return "unknown format"
+	}
+	return ""
+}
+
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+
+	vs, err := h.st.GetUserSettings(r.Context(), login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get settings")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"settings": vs})
+}
+
+func (h *Handler) PutSettings(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+
+	// неуказанные поля остаются прежними
+	vs, err := h.st.GetUserSettings(r.Context(), login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get settings")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&vs); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	if msg := validateVoiceSettings(vs); msg != "" {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, msg)
+		return
+	}
+
+	if err := h.st.SetUserSettings(r.Context(), login, vs); err != nil {
+		writeInternal(w, r, err, "failed to save settings")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"settings": vs})
+}
+
+func (h *Handler) GetBookSettings(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
+		return
+	}
+
+	if _, err := h.st.GetBook(r.Context(), bookID, login); err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return
+	}
+
+	vs, err := h.st.GetUserSettings(r.Context(), login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get settings")
+		return
+	}
+	o, err := h.st.GetBookSettings(r.Context(), login, bookID)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get settings")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"overrides": o,
+		"settings":  o.Apply(vs),
+	})
+}
+
+// PutBookSettings полностью заменяет переопределения книги; null сбрасывает поле к настройкам пользователя
+func (h *Handler) PutBookSettings(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
+		return
+	}
+
+	if _, err := h.st.GetBook(r.Context(), bookID, login); err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return
+	}
+
+	var o storage.BookVoiceSettings
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+
+	vs, err := h.st.GetUserSettings(r.Context(), login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get settings")
+		return
+	}
+	effective := o.Apply(vs)
+	if msg := validateVoiceSettings(effective); msg != "" {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, msg)
+		return
+	}
+
+	if err := h.st.SetBookSettings(r.Context(), login, bookID, o); err != nil {
+		writeInternal(w, r, err, "failed to save settings")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"overrides": o,
+		"settings":  effective,
+	})
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"voicebook/internal/logging"
+	"voicebook/internal/storage"
+	"voicebook/internal/token"
+	"voicebook/internal/validate"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type shareRequest struct {
+	// Login — кому открыть книгу; без него создаётся ссылка-приглашение
+	Login string `json:"login"`
+	// ExpiresIn — срок доступа в секундах; 0 — бессрочно
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+type inviteRequest struct {
+	Token string `json:"token"`
+}
+
+// ownBook возвращает книгу из URL, если её владелец — текущий пользователь; иначе сам пишет ответ
+func (h *Handler) ownBook(w http.ResponseWriter, r *http.Request) (storage.Book, bool) {
+	login := r.Context().Value("login").(string)
+	bookID, err := strconv.ParseInt(chi.URLParam(r, "bookId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
+		return storage.Book{}, false
+	}
+
+	book, err := h.st.GetBook(r.Context(), bookID, login)
+	if err == nil && (book.Shared || book.Library) {
+		err = storage.ErrNotBookOwner
+	}
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return storage.Book{}, false
+	}
+	return book, true
+}
+
+// GetBookShares — кому открыта книга и какие приглашения действуют (только владельцу)
+func (h *Handler) GetBookShares(w http.ResponseWriter, r *http.Request) {
+	book, ok := h.ownBook(w, r)
+	if !ok {
+		return
+	}
+
+	shares, err := h.st.GetBookShares(r.Context(), book.BookID)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get shares")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"shares": shares})
+}
+
+// PostBookShare открывает книгу на чтение другому пользователю или создаёт
+// ссылку-приглашение. Токен приглашения показывается только в этом ответе.
+func (h *Handler) PostBookShare(w http.ResponseWriter, r *http.Request) {
+	book, ok := h.ownBook(w, r)
+	if !ok {
+		return
+	}
+
+	var req shareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	req.Login = strings.TrimSpace(req.Login)
+	var errs validate.Errors
+	if req.ExpiresIn < 0 {
+		errs.Check("expiresIn", "must not be negative")
+	}
+	if req.Login == book.Login {
+		errs.Check("login", "cannot share a book with its owner")
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
+		return
+	}
+
+	var expires time.Time
+	if req.ExpiresIn > 0 {
+		expires = time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+	}
+
+	if req.Login != "" {
+		share, err := h.st.ShareBook(r.Context(), book.BookID, req.Login, expires)
+		if err != nil {
+			writeStorageError(w, r, err, "failed to share book")
+			return
+		}
+		logging.FromContext(r.Context()).Info("book shared", "book_id", book.BookID, "recipient", req.Login)
+		writeJSON(w, http.StatusCreated, map[string]any{"share": share})
+		return
+	}
+
+	tok := randomString()
+	share, err := h.st.CreateInvite(r.Context(), book.BookID, token.Hash(tok), expires)
+	if err != nil {
+		writeInternal(w, r, err, "failed to create invite")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"share":     share,
+		"token":     tok,
+		"inviteUrl": h.accountLink("/invite", tok),
+	})
+}
+
+// DeleteBookShare отзывает доступ или приглашение. Отзыв приглашения не забирает
+// доступ у тех, кто уже его принял: их доступы отзываются отдельно.
+func (h *Handler) DeleteBookShare(w http.ResponseWriter, r *http.Request) {
+	book, ok := h.ownBook(w, r)
+	if !ok {
+		return
+	}
+	shareID, err := strconv.ParseInt(chi.URLParam(r, "shareId"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid shareId")
+		return
+	}
+
+	if err := h.st.DeleteBookShare(r.Context(), book.BookID, shareID); err != nil {
+		writeStorageError(w, r, err, "failed to revoke share")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvite добавляет книгу из приглашения в коллекцию текущего пользователя
+func (h *Handler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("login").(string)
+
+	var req inviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "token is required")
+		return
+	}
+
+	bookID, err := h.st.AcceptInvite(r.Context(), token.Hash(req.Token), login)
+	if errors.Is(err, storage.ErrInviteInvalid) {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeInternal(w, r, err, "failed to accept invite")
+		return
+	}
+
+	book, err := h.st.GetBook(r.Context(), bookID, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get book")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"book": book})
+}
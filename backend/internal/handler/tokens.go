@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"voicebook/internal/storage"
+	"voicebook/internal/token"
+
+	"github.com/google/uuid"
+)
+
+// authResponse — ответ входа и регистрации. Поля токенов заполняются,
+// только если вход по access-токенам включён.
+type authResponse struct {
+	SessionID    string `json:"sessionId,omitempty"`
+	AccessToken  string `json:"accessToken,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	TokenType    string `json:"tokenType,omitempty"`
+	ExpiresIn    int64  `json:"expiresIn,omitempty"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// startSession открывает сессию и отвечает её данными
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, login string) {
+	resp, err := h.newSession(r, login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to start session")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// newSession открывает сессию для X-Session-Id и, если можно, выдаёт пару токенов.
+// Отключённому пользователю — storage.ErrUserDisabled.
+func (h *Handler) newSession(r *http.Request, login string) (authResponse, error) {
+	resp := authResponse{SessionID: uuid.NewString()}
+	if _, disabled, err := h.st.GetUserAccess(r.Context(), login); err != nil {
+		return resp, err
+	} else if disabled {
+		return resp, storage.ErrUserDisabled
+	}
+	if err := h.st.SaveSession(r.Context(), login, resp.SessionID); err != nil {
+		return resp, err
+	}
+	if h.auth.Tokens != nil {
+		if err := h.issueTokens(r, login, &resp); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// RefreshToken меняет refresh-токен на новую пару токенов; старый refresh-токен
+// после этого не принимается
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if h.auth.Tokens == nil {
+		writeError(w, r, http.StatusNotFound, codeNotFound, "token authentication is disabled")
+		return
+	}
+
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "refreshToken is required")
+		return
+	}
+
+	login, err := h.st.UseRefreshToken(r.Context(), token.Hash(req.RefreshToken))
+	if errors.Is(err, storage.ErrRefreshTokenInvalid) {
+		writeError(w, r, http.StatusUnauthorized, codeUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		writeInternal(w, r, err, "failed to check refresh token")
+		return
+	}
+	// отключение и удаление отзывают refresh-токены, но выданный до этого мог успеть
+	// пройти проверку выше
+	active, err := h.st.UserActive(r.Context(), login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to check user")
+		return
+	}
+	if !active {
+		writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "account is disabled")
+		return
+	}
+
+	var resp authResponse
+	if err := h.issueTokens(r, login, &resp); err != nil {
+		writeInternal(w, r, err, "failed to issue tokens")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) issueTokens(r *http.Request, login string, resp *authResponse) error {
+	access, err := h.auth.Tokens.Issue(login)
+	if err != nil {
+		return err
+	}
+	refresh, err := token.NewRefresh()
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(h.auth.RefreshTTL)
+	if err := h.st.SaveRefreshToken(r.Context(), login, token.Hash(refresh), expires); err != nil {
+		return err
+	}
+
+	resp.AccessToken = access
+	resp.RefreshToken = refresh
+	resp.TokenType = "Bearer"
+	resp.ExpiresIn = int64(h.auth.Tokens.TTL().Seconds())
+	return nil
+}
+
+// bearerToken достаёт токен из заголовка Authorization: Bearer <token>
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, tok, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || tok == "" {
+		return "", false
+	}
+	return strings.TrimSpace(tok), true
+}
@@ -1,39 +1,74 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
-
+	"time"
+
+	"voicebook/internal/account"
+	"voicebook/internal/export"
+	"voicebook/internal/logging"
+	"voicebook/internal/mailer"
+	"voicebook/internal/metrics"
+	"voicebook/internal/oidc"
 	"voicebook/internal/s3client"
 	"voicebook/internal/storage"
-
-	"github.com/google/uuid"
+	"voicebook/internal/token"
+	"voicebook/internal/transcode"
+	"voicebook/internal/tts"
+	"voicebook/internal/validate"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type Handler struct {
-	st *storage.Storage
-	cl *s3client.Client
-	Mock *httptest.Server
+	st  *storage.Storage
+	cl  *s3client.Client
+	tts *tts.Client
+	ex  *export.Service
+	del *account.Deleter
+	// enc — nil, если ffmpeg недоступен
+	enc  *transcode.FFmpeg
+	auth Auth
+	// quota — лимиты по умолчанию, если администратор не задал свои
+	quota storage.Quota
+	// library — включён публичный каталог
+	library bool
+	Mock    *httptest.Server
 }
 
-type Config struct {
-	TTSBaseURL string
+// Auth — необязательные способы входа помимо пароля и X-Session-Id
+type Auth struct {
+	// Tokens — nil, если вход по access-токенам выключен
+	Tokens     *token.Issuer
+	RefreshTTL time.Duration
+	// OIDC — nil, если вход через внешнего провайдера выключен
+	OIDC         *oidc.Provider
+	PostLoginURL string
+	// Mailer отправляет письма подтверждения email и сброса пароля;
+	// ссылки в них ведут на фронтенд PublicURL
+	Mailer    mailer.Mailer
+	PublicURL string
+	VerifyTTL time.Duration
+	ResetTTL  time.Duration
 }
 
-
-func New(st *storage.Storage, cl *s3client.Client ) *Handler {
-	return &Handler{st: st, cl: cl}
+func New(st *storage.Storage, cl *s3client.Client, tc *tts.Client, ex *export.Service, del *account.Deleter, enc *transcode.FFmpeg, auth Auth, quota storage.Quota, library bool) *Handler {
+	return &Handler{st: st, cl: cl, tts: tc, ex: ex, del: del, enc: enc, auth: auth, quota: quota, library: library}
 }
 
+// максимальный размер загружаемой книги
+const maxBookSize = 10 << 20
+
 type PostBookRequest struct {
-    BookTitle string `json:"bookTitle"`
+	BookTitle string `json:"bookTitle"`
 }
 
 type credentials struct {
@@ -43,54 +78,93 @@ type credentials struct {
 
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var cred credentials
-	body, _ := io.ReadAll(r.Body)
-	json.Unmarshal(body, &cred)
+	if err := json.NewDecoder(r.Body).Decode(&cred); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
 
-	if err := h.st.CreateUser(cred.Login, cred.Password); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var errs validate.Errors
+	errs.Check("login", validate.Login(cred.Login))
+	errs.Check("password", validate.Password(cred.Password))
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
 		return
 	}
 
-	sessionID := uuid.NewString()
-	h.st.SaveSession(cred.Login, sessionID)
+	if err := h.st.CreateUser(r.Context(), cred.Login, cred.Password); err != nil {
+		writeStorageError(w, r, err, "failed to create user")
+		return
+	}
 
-	json.NewEncoder(w).Encode(map[string]string{"sessionId": sessionID})
+	h.startSession(w, r, cred.Login)
 }
 
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	var cred credentials
-	body, _ := io.ReadAll(r.Body)
-	json.Unmarshal(body, &cred)
-
-	ok, err := h.st.GetUser(cred.Login, cred.Password)
-	if err != nil || !ok {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+	if err := json.NewDecoder(r.Body).Decode(&cred); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	// правила сложности здесь не проверяем: они могли поменяться после регистрации
+	var errs validate.Errors
+	errs.Check("login", validate.Text(cred.Login, true, validate.LoginMaxLen))
+	errs.Check("password", validate.Text(cred.Password, true, validate.PasswordMaxLen))
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
 		return
 	}
 
-	sessionID := uuid.NewString()
-
-	h.st.SaveSession(cred.Login, sessionID)
+	ok, err := h.st.GetUser(r.Context(), cred.Login, cred.Password)
+	if err != nil {
+		writeInternal(w, r, err, "failed to check credentials")
+		return
+	}
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "invalid credentials")
+		return
+	}
 
-	json.NewEncoder(w).Encode(map[string]string{"sessionId": sessionID})
+	h.startSession(w, r, cred.Login)
 }
 
 func (h *Handler) Myself(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("login").(string)
-	json.NewEncoder(w).Encode(map[string]string{"login": login})
+	u, err := h.st.GetUserInfo(r.Context(), login)
+	if err != nil {
+		writeStorageError(w, r, err, "failed to get user")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"login": login, "email": u.Email, "role": u.Role})
 
 }
 
+// Logout закрывает сессию X-Session-Id и отзывает refresh-токен из тела запроса.
+// Клиент с одним access-токеном отзывает так все свои refresh-токены.
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
-	sessionID := r.Header.Get("X-Session-Id")
-	if sessionID == "" {
-		http.Error(w, "missing session id", http.StatusBadRequest)
+	login := r.Context().Value("login").(string)
+
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
 		return
 	}
 
-	err := h.st.DeleteSession(sessionID)
+	if sessionID := r.Header.Get("X-Session-Id"); sessionID != "" {
+		if err := h.st.DeleteSession(r.Context(), sessionID); err != nil {
+			writeInternal(w, r, err, "failed to delete session")
+			return
+		}
+	}
+
+	var err error
+	switch _, bearer := bearerToken(r); {
+	case req.RefreshToken != "":
+		err = h.st.RevokeRefreshToken(r.Context(), token.Hash(req.RefreshToken))
+	case bearer:
+		err = h.st.RevokeRefreshTokens(r.Context(), login)
+	}
 	if err != nil {
-		http.Error(w, "failed to delete session", http.StatusInternalServerError)
+		writeInternal(w, r, err, "failed to revoke refresh token")
 		return
 	}
 
@@ -103,115 +177,165 @@ func (h *Handler) GetBook(w http.ResponseWriter, r *http.Request) {
 	bookIDStr := chi.URLParam(r, "bookId")
 	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid bookId", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
 		return
 	}
 
-	book, err := h.st.GetBook(bookID, login)
+	book, err := h.st.GetBook(r.Context(), bookID, login)
 	if err != nil {
-		http.Error(w, "book not found", http.StatusNotFound)
+		writeStorageError(w, r, err, "failed to get book")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]any{"book": book})
+	writeJSON(w, http.StatusOK, map[string]any{"book": book})
 }
 
 func (h *Handler) PostBook(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("login").(string)
 
+	// запас сверху — на остальные поля формы и разметку multipart
+	r.Body = http.MaxBytesReader(w, r.Body, maxBookSize+1<<20)
 	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, "failed to parse multipart form", http.StatusBadRequest)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, codeTooLarge,
+				fmt.Sprintf("request body must be at most %d bytes", tooLarge.Limit))
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "failed to parse multipart form")
 		return
 	}
 
+	bookTitle := strings.TrimSpace(r.FormValue("bookTitle"))
+	bookAuthor := strings.TrimSpace(r.FormValue("author"))
+
+	var errs validate.Errors
+	errs.Check("bookTitle", validate.Text(bookTitle, true, validate.TitleMaxLen))
+	errs.Check("author", validate.Text(bookAuthor, false, validate.AuthorMaxLen))
+
+	var data []byte
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, "file is required", http.StatusBadRequest)
-		return
+		errs.Check("file", "is required")
+	} else {
+		data, err = io.ReadAll(file)
+		if err != nil {
+			writeInternal(w, r, err, "failed to read file")
+			return
+		}
+		errs.Check("file", validate.BookFile(data, maxBookSize))
 	}
-
-	bookTitle := r.FormValue("bookTitle")
-	if bookTitle == "" {
-		http.Error(w, "bookTitle is required", http.StatusBadRequest)
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs)
 		return
 	}
-	bookAuthor := r.FormValue("author")
-	if bookTitle == "" {
-		http.Error(w, "bookAuthor is required", http.StatusBadRequest)
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+
+	// книга уже есть в каталоге: вместо второй копии — ссылка, место она не занимает
+	if h.addLibraryCopy(w, r, login, string(data)) {
 		return
 	}
-	data, err := io.ReadAll(file)
-	if err != nil {
-		http.Error(w, "failed to read file", http.StatusInternalServerError)
+
+	if !h.checkUploadQuota(w, r, login, int64(len(data))) {
 		return
 	}
 
 	url, err := h.cl.UploadFile(r.Context(), data, header.Filename)
 	if err != nil {
-		http.Error(w, "failed to upload file", http.StatusInternalServerError)
+		metrics.BookUploaded(false)
+		writeInternal(w, r, err, "failed to upload file")
 		return
 	}
 
 	fullText := string(data)
-	book, err := h.st.AddBook(login, url, bookTitle, bookAuthor, fullText)
+	book, err := h.st.AddBook(r.Context(), login, url, bookTitle, bookAuthor, fullText)
 	if err != nil {
-		fmt.Println("AddBook failed:", err)
-		http.Error(w, "failed to add book", http.StatusInternalServerError)
+		metrics.BookUploaded(false)
+		writeInternal(w, r, err, "failed to add book")
 		return
 	}
+	metrics.BookUploaded(true)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{"book": book})
+	writeJSON(w, http.StatusOK, map[string]any{"book": book})
 }
 
-
-
 func (h *Handler) DeleteBook(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("login").(string)
 	bookIDStr := chi.URLParam(r, "bookId")
 	bookID, err := strconv.ParseInt(bookIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid bookId", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid bookId")
 		return
 	}
 
-	book, err := h.st.GetBook(bookID, login)
+	book, err := h.st.GetBook(r.Context(), bookID, login)
+	if err == nil && book.Shared {
+		err = storage.ErrNotBookOwner
+	}
 	if err != nil {
-		http.Error(w, "invalid bookId", http.StatusBadRequest)
+		writeStorageError(w, r, err, "failed to get book")
+		return
+	}
+	// книга каталога общая: из коллекции убирается только ссылка
+	if book.Library {
+		if err := h.st.RemoveLibraryRef(r.Context(), login, bookID); err != nil {
+			writeStorageError(w, r, err, "failed to remove book from collection")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
 		return
 	}
 	fileName := strings.Split(book.BookUrl, "uploads/")[1]
 
 	err = h.cl.DeleteFile(r.Context(), fileName)
 	if err != nil {
-		http.Error(w, "failed to delete book from s3", http.StatusBadRequest)
+		writeInternal(w, r, err, "failed to delete book from s3")
 		return
 	}
-	err = h.st.DeleteBook(bookID, login)
+	exportURLs, err := h.st.DeleteBook(r.Context(), bookID, login)
 	if err != nil {
-		http.Error(w, "failed to delete book from postgres", http.StatusInternalServerError)
+		writeStorageError(w, r, err, "failed to delete book from postgres")
 		return
 	}
+	for _, u := range exportURLs {
+		if err := h.cl.DeleteObject(r.Context(), u); err != nil {
+			logging.FromContext(r.Context()).Error("failed to delete export of deleted book", "book_id", bookID, "url", u, "error", err)
+		}
+	}
 
-	json.NewEncoder(w).Encode(map[string]string{"message": "ok"})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
 }
 
 func (h *Handler) GetCollection(w http.ResponseWriter, r *http.Request) {
-    login := r.Context().Value("login").(string)
+	login := r.Context().Value("login").(string)
 
-    books, err := h.st.GetUserBooks(login)
-    if err != nil {
-        http.Error(w, "failed to get collection", http.StatusInternalServerError)
-        return
-    }
+	books, err := h.st.GetUserBooks(r.Context(), login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get collection")
+		return
+	}
+	// открытые другими пользователями книги идут после своих, с shared: true
+	shared, err := h.st.GetSharedBooks(r.Context(), login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get collection")
+		return
+	}
+	books = append(books, shared...)
+	// за ними книги каталога, с library: true
+	refs, err := h.st.GetLibraryRefs(r.Context(), login)
+	if err != nil {
+		writeInternal(w, r, err, "failed to get collection")
+		return
+	}
+	books = append(books, refs...)
 
-    response := struct {
-        Collection struct {
-            Books []storage.Book `json:"books"`
-        } `json:"collection"`
-    }{}
+	response := struct {
+		Collection struct {
+			Books []storage.Book `json:"books"`
+		} `json:"collection"`
+	}{}
 
-    response.Collection.Books = books
+	response.Collection.Books = books
 
-    json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check проверяет одну зависимость; nil — зависимость доступна
+type Check func(ctx context.Context) error
+
+type Result struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+	CheckedAt int64  `json:"checkedTs"`
+}
+
+// Checker запускает проверки параллельно и кеширует результат на ttl,
+// чтобы частые пробы оркестратора не нагружали базу, S3 и TTS
+type Checker struct {
+	checks  map[string]Check
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu      sync.Mutex
+	cached  map[string]Result
+	expires time.Time
+}
+
+func New(ttl, timeout time.Duration) *Checker {
+	return &Checker{
+		checks:  make(map[string]Check),
+		ttl:     ttl,
+		timeout: timeout,
+	}
+}
+
+// Add регистрирует проверку; вызывать до начала обслуживания запросов
+func (c *Checker) Add(name string, check Check) {
+	c.checks[name] = check
+}
+
+// Run возвращает результаты всех проверок и true, если все прошли
+func (c *Checker) Run(ctx context.Context) (map[string]Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached == nil || time.Now().After(c.expires) {
+		c.cached = c.runAll(ctx)
+		c.expires = time.Now().Add(c.ttl)
+	}
+
+	ok := true
+	for _, r := range c.cached {
+		if r.Status != StatusOK {
+			ok = false
+		}
+	}
+	return c.cached, ok
+}
+
+func (c *Checker) runAll(ctx context.Context) map[string]Result {
+	// результат попадёт в кеш, поэтому отмена запроса, запустившего проверку, не должна его портить
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]Result, len(c.checks))
+	for name, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := check(ctx)
+			r := Result{
+				Status:    StatusOK,
+				LatencyMs: time.Since(start).Milliseconds(),
+				CheckedAt: start.Unix(),
+			}
+			if err != nil {
+				r.Status = StatusFail
+				r.Error = err.Error()
+			}
+			mu.Lock()
+			results[name] = r
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Live — liveness: процесс жив и обслуживает HTTP, зависимости не проверяются
+func Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": StatusOK})
+}
+
+// Ready — readiness: 503, пока хоть одна зависимость недоступна
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	results, ok := c.Run(r.Context())
+
+	status, code := StatusOK, http.StatusOK
+	if !ok {
+		status, code = StatusFail, http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": status,
+		"checks": results,
+	})
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type ctxKey int
+
+const (
+	loggerKey ctxKey = iota
+	requestKey
+)
+
+// New создаёт логгер с форматом format (json или text) и уровнем level (debug, info, warn, error)
+func New(level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+
+	var h slog.Handler
+	if strings.EqualFold(format, "json") {
+		h = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		h = slog.NewTextHandler(os.Stdout, opts)
+	}
+	return slog.New(h)
+}
+
+func parseLevel(s string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// WithLogger кладёт логгер в контекст; обычно это логгер запроса с request_id
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext возвращает логгер запроса или slog.Default вне запроса
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// Request — данные запроса, которые внутренние обработчики сообщают
+// внешнему middleware логирования (контекст наружу не возвращается)
+type Request struct {
+	ID    string
+	Login string
+}
+
+func WithRequest(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestKey, req)
+}
+
+func RequestFromContext(ctx context.Context) *Request {
+	req, _ := ctx.Value(requestKey).(*Request)
+	return req
+}
+
+// SetLogin запоминает пользователя запроса после авторизации
+// и добавляет его в логгер для дальнейших записей
+func SetLogin(ctx context.Context, login string) context.Context {
+	if req := RequestFromContext(ctx); req != nil {
+		req.Login = login
+	}
+	return WithLogger(ctx, FromContext(ctx).With("login", login))
+}
@@ -0,0 +1,127 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"voicebook/internal/config"
+	"voicebook/internal/logging"
+
+	"github.com/google/uuid"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма; реализации выбираются в конфигурации (mail.driver)
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New выбирает реализацию: smtp — настоящая отправка, file — письма .eml в каталог,
+// log — письмо целиком в лог (для локальной разработки)
+func New(cfg config.Mail, logger *slog.Logger) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return &SMTP{
+			addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+			host: cfg.SMTPHost,
+			user: cfg.SMTPUser,
+			pass: cfg.SMTPPassword,
+			from: cfg.From,
+		}, nil
+	case "file":
+		if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create mail dir: %w", err)
+		}
+		return &File{dir: cfg.Dir, from: cfg.From}, nil
+	case "log", "":
+		return &Log{logger: logger}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// SMTP отправляет через сервер с авторизацией PLAIN; STARTTLS включается,
+// если сервер его предлагает
+type SMTP struct {
+	addr string
+	host string
+	user string
+	pass string
+	from string
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if s.user != "" {
+		auth = smtp.PlainAuth("", s.user, s.pass, s.host)
+	}
+	if err := smtp.SendMail(s.addr, auth, envelopeFrom(s.from), []string{msg.To}, render(s.from, msg)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// File складывает письма в каталог — удобно проверять ссылки из писем без почтового сервера
+type File struct {
+	dir  string
+	from string
+}
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	name := time.Now().Format("20060102-150405") + "-" + uuid.NewString()[:8] + ".eml"
+	path := filepath.Join(f.dir, name)
+	if err := os.WriteFile(path, render(f.from, msg), 0o600); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	logging.FromContext(ctx).Info("mail written to file", "path", path, "to", msg.To)
+	return nil
+}
+
+type Log struct {
+	logger *slog.Logger
+}
+
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	l.logger.InfoContext(ctx, "mail not sent, logged instead",
+		"to", msg.To,
+		"subject", msg.Subject,
+		"body", msg.Body,
+	)
+	return nil
+}
+
+// render собирает письмо в формате RFC 5322 с телом в UTF-8
+func render(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// envelopeFrom достаёт адрес из "Имя <addr>"
+func envelopeFrom(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		return strings.TrimSuffix(from[i+1:], ">")
+	}
+	return from
+}
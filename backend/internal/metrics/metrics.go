@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "voicebook"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by chi route pattern and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by chi route pattern and status.",
+		// синтез страницы занимает секунды, поэтому верхние корзины крупнее стандартных
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method", "route", "status"})
+
+	ttsDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tts_request_duration_seconds",
+		Help:      "TTS service call latency.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"operation"})
+
+	ttsFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tts_failures_total",
+		Help:      "Failed TTS service calls.",
+	}, []string{"operation"})
+
+	s3Duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "s3_operation_duration_seconds",
+		Help:      "Object storage operation latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+
+	s3Bytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "s3_bytes_total",
+		Help:      "Bytes transferred to and from object storage.",
+	}, []string{"direction"})
+
+	exportJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_jobs_total",
+		Help:      "Finished export jobs by format and result.",
+	}, []string{"format", "result"})
+
+	exportJobsRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "export_jobs_running",
+		Help:      "Export jobs being built right now.",
+	})
+
+	booksUploaded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "books_uploaded_total",
+		Help:      "Uploaded books by result.",
+	}, []string{"result"})
+
+	audioCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audio_cache_requests_total",
+		Help:      "Audio cache lookups: tts — synthesized pages, variant — transcoded copies.",
+	}, []string{"cache", "result"})
+)
+
+// RegisterDB публикует статистику пула соединений sql.DB
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+func ObserveHTTP(method, route string, status int, d time.Duration) {
+	s := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, s).Inc()
+	httpDuration.WithLabelValues(method, route, s).Observe(d.Seconds())
+}
+
+func ObserveTTS(operation string, start time.Time, err error) {
+	ttsDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		ttsFailures.WithLabelValues(operation).Inc()
+	}
+}
+
+func ObserveS3(operation string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	s3Duration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+func AddS3Bytes(direction string, n int64) {
+	if n > 0 {
+		s3Bytes.WithLabelValues(direction).Add(float64(n))
+	}
+}
+
+func ExportStarted() {
+	exportJobsRunning.Inc()
+}
+
+func ExportFinished(format, result string) {
+	exportJobsRunning.Dec()
+	exportJobs.WithLabelValues(format, result).Inc()
+}
+
+func BookUploaded(ok bool) {
+	booksUploaded.WithLabelValues(okLabel(ok)).Inc()
+}
+
+func AudioCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	audioCache.WithLabelValues(cache, result).Inc()
+}
+
+func okLabel(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "error"
+}
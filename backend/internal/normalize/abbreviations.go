@@ -0,0 +1,90 @@
+package normalize
+
+import (
+	"regexp"
+	"strings"
+)
+
+type abbreviation struct {
+	re   *regexp.Regexp
+	word string
+	// final — сокращение может закрывать предложение ("и т.д."): точка сохраняется,
+	// если дальше не идёт продолжение фразы
+	final bool
+}
+
+func abbr(pattern, word string) abbreviation {
+	re := `(?m)(^|[^\p{L}])(?:` + pattern + `)`
+	if !strings.HasSuffix(pattern, `\.`) {
+		re += `([^\p{L}]|$)`
+	} else {
+		re += `()`
+	}
+	return abbreviation{re: regexp.MustCompile(re), word: word}
+}
+
+func finalAbbr(pattern, word string) abbreviation {
+	return abbreviation{
+		re:    regexp.MustCompile(`(?m)(^|[^\p{L}])(?:` + pattern + `)(\s*[,;:)]|[ \t]+\p{Ll})?`),
+		word:  word,
+		final: true,
+	}
+}
+
+var ruAbbreviations = []abbreviation{
+	finalAbbr(`и\s?т\.\s?д\.`, "и так далее"),
+	finalAbbr(`и\s?т\.\s?п\.`, "и тому подобное"),
+	finalAbbr(`и\s?др\.`, "и другие"),
+	finalAbbr(`и\s?пр\.`, "и прочее"),
+	abbr(`т\.\s?е\.`, "то есть"),
+	abbr(`т\.\s?к\.`, "так как"),
+	abbr(`т\.\s?н\.`, "так называемый"),
+	abbr(`напр\.`, "например"),
+	abbr(`см\.`, "смотри"),
+	abbr(`ср\.`, "сравни"),
+	abbr(`стр\.`, "страница"),
+	abbr(`г-н`, "господин"),
+	abbr(`г-жа`, "госпожа"),
+	abbr(`ул\.`, "улица"),
+	abbr(`пр-т`, "проспект"),
+	abbr(`им\.`, "имени"),
+	abbr(`проф\.`, "профессор"),
+	abbr(`акад\.`, "академик"),
+	abbr(`тыс\.`, "тысяч"),
+	abbr(`млн`, "миллионов"),
+	abbr(`млрд`, "миллиардов"),
+	abbr(`руб\.`, "рублей"),
+}
+
+var enAbbreviations = []abbreviation{
+	finalAbbr(`etc\.`, "et cetera"),
+	abbr(`Mr\.`, "Mister"),
+	abbr(`Mrs\.`, "Missus"),
+	abbr(`Ms\.`, "Miz"),
+	abbr(`Dr\.`, "Doctor"),
+	abbr(`St\.`, "Saint"),
+	abbr(`Mt\.`, "Mount"),
+	abbr(`Prof\.`, "Professor"),
+	abbr(`Jr\.`, "Junior"),
+	abbr(`Sr\.`, "Senior"),
+	abbr(`(?i:e\.\s?g\.)`, "for example"),
+	abbr(`(?i:i\.\s?e\.)`, "that is"),
+	abbr(`vs\.`, "versus"),
+	abbr(`approx\.`, "approximately"),
+}
+
+func expandAbbreviations(text string, list []abbreviation) string {
+	for _, a := range list {
+		// два прохода: граничный символ одного совпадения мог быть началом следующего
+		for i := 0; i < 2; i++ {
+			text = replaceSubmatch(a.re, text, func(m []string) string {
+				tail := m[2]
+				if a.final && tail == "" {
+					return m[1] + a.word + "."
+				}
+				return m[1] + a.word + tail
+			})
+		}
+	}
+	return text
+}
@@ -0,0 +1,271 @@
+package normalize
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var enOnes = [20]string{
+	"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+	"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen",
+	"seventeen", "eighteen", "nineteen",
+}
+
+var enTens = [10]string{
+	"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety",
+}
+
+var enOrdinalIrregular = map[string]string{
+	"one": "first", "two": "second", "three": "third", "five": "fifth",
+	"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+}
+
+var enMonths = `January|February|March|April|May|June|July|August|September|October|November|December`
+
+var (
+	enMonthDayRe = regexp.MustCompile(`\b(` + enMonths + `)\s+(\d{1,2})(?:st|nd|rd|th)?\b`)
+	enDayMonthRe = regexp.MustCompile(`\b(\d{1,2})(?:st|nd|rd|th)?\s+(` + enMonths + `)\b`)
+	enYearRe     = regexp.MustCompile(`\b((?i:in|of|since|by|until|till|from|year)\s+|(?:` + enMonths + `)(?:\s+[\w-]+)?,?\s+)(\d{4})\b`)
+	enOrdinalRe  = regexp.MustCompile(`\b(\d+)(?:st|nd|rd|th)\b`)
+	enNumberNoRe = regexp.MustCompile(`\bNo\.\s*(\d)`)
+	// No. в начале предложения читается с заглавной
+	enNumberNoStartRe = regexp.MustCompile(`(?m)(^[ \t]*|[.!?][ \t]+)No\.\s*(\d)`)
+	enTimeRe          = regexp.MustCompile(`\b([01]?\d|2[0-3]):([0-5]\d)\b(?:\s*(?i:([ap])\.?m\b(\.?)))?`)
+	// час без минут — только с am/pm, иначе это не время; группа минут пустая, как в enTimeRe
+	enHourRe      = regexp.MustCompile(`\b(1[0-2]|0?[1-9])()\s*(?i:([ap])\.?m\b(\.?))`)
+	enYearRangeRe = regexp.MustCompile(`\b(1\d{3}|20\d{2})\s*[-–]\s*(1\d{3}|20\d{2})\b`)
+	enDollarRe    = regexp.MustCompile(`\$\s?(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d{1,2}))?`)
+	enPercentRe   = regexp.MustCompile(`\b(\d+(?:\.\d+)?)\s*%`)
+	enDecimalRe   = regexp.MustCompile(`\b(\d+)\.(\d+)\b`)
+	enNumberRe    = regexp.MustCompile(`\b(\d{1,3}(?:,\d{3})+|\d+)\b`)
+)
+
+func enNumbers(text string) string {
+	text = enNumberNoStartRe.ReplaceAllString(text, "${1}Number $2")
+	text = enNumberNoRe.ReplaceAllString(text, "number $1")
+
+	// 1999-2000 → nineteen ninety-nine to two thousand
+	text = replaceSubmatch(enYearRangeRe, text, func(m []string) string {
+		from, _ := strconv.ParseInt(m[1], 10, 64)
+		to, _ := strconv.ParseInt(m[2], 10, 64)
+		if to <= from {
+			return m[0]
+		}
+		return enYear(from) + " to " + enYear(to)
+	})
+	text = minusRe.ReplaceAllString(text, "${1}minus $2")
+
+	// 3:45 pm → three forty-five P M, 10:05 → ten oh five, 10:00 → ten o'clock, 6 am → six A M
+	enTime := func(m []string, next string) string {
+		hour, _ := strconv.ParseInt(m[1], 10, 64)
+		minute, _ := strconv.ParseInt(m[2], 10, 64)
+		words := enCardinal(hour)
+		switch {
+		case minute == 0 && m[3] == "":
+			words += " o'clock"
+		case minute == 0:
+		case minute < 10:
+			words += " oh " + enOnes[minute]
+		default:
+			words += " " + enCardinal(minute)
+		}
+		if m[3] == "" {
+			return words
+		}
+		words += " " + strings.ToUpper(m[3]) + " M"
+		// точка сокращения в конце предложения — она же и конец предложения
+		if m[4] != "" && endsSentence(next[:min(len(next), 1)], next[min(len(next), 1):]) {
+			words += "."
+		}
+		return words
+	}
+	text = replaceSubmatchNext(enTimeRe, text, enTime)
+	text = replaceSubmatchNext(enHourRe, text, enTime)
+
+	// $3.50 → three dollars and fifty cents
+	text = replaceSubmatch(enDollarRe, text, func(m []string) string {
+		n, err := strconv.ParseInt(strings.ReplaceAll(m[1], ",", ""), 10, 64)
+		if err != nil {
+			return m[0]
+		}
+		var cents int64
+		if m[2] != "" {
+			cents, _ = strconv.ParseInt(m[2], 10, 64)
+			if len(m[2]) == 1 {
+				cents *= 10
+			}
+		}
+		switch {
+		case cents == 0:
+			return enCount(n, "dollar")
+		case n == 0:
+			return enCount(cents, "cent")
+		default:
+			return enCount(n, "dollar") + " and " + enCount(cents, "cent")
+		}
+	})
+
+	// May 5 → May fifth
+	text = replaceSubmatch(enMonthDayRe, text, func(m []string) string {
+		day, _ := strconv.ParseInt(m[2], 10, 64)
+		if day < 1 || day > 31 {
+			return m[0]
+		}
+		return m[1] + " " + enOrdinal(day)
+	})
+
+	// 5 May → the fifth of May
+	text = replaceSubmatch(enDayMonthRe, text, func(m []string) string {
+		day, _ := strconv.ParseInt(m[1], 10, 64)
+		if day < 1 || day > 31 {
+			return m[0]
+		}
+		return "the " + enOrdinal(day) + " of " + m[2]
+	})
+
+	// in 1812 → in eighteen twelve
+	text = replaceSubmatch(enYearRe, text, func(m []string) string {
+		year, _ := strconv.ParseInt(m[2], 10, 64)
+		return m[1] + enYear(year)
+	})
+
+	text = replaceSubmatch(enOrdinalRe, text, func(m []string) string {
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return m[0]
+		}
+		return enOrdinal(n)
+	})
+
+	text = replaceSubmatch(enPercentRe, text, func(m []string) string {
+		return enDecimal(m[1]) + " percent"
+	})
+
+	text = replaceSubmatch(enDecimalRe, text, func(m []string) string {
+		return enDecimal(m[0])
+	})
+
+	text = replaceSubmatch(enNumberRe, text, func(m []string) string {
+		n, err := strconv.ParseInt(strings.ReplaceAll(m[1], ",", ""), 10, 64)
+		if err != nil {
+			return m[0]
+		}
+		return enCardinal(n)
+	})
+
+	return text
+}
+
+// enCardinal записывает число словами по-английски
+func enCardinal(n int64) string {
+	if n < 0 {
+		return "minus " + enCardinal(-n)
+	}
+	if n < 20 {
+		return enOnes[n]
+	}
+	if n >= 1e12 {
+		return enDigits(strconv.FormatInt(n, 10))
+	}
+
+	var words []string
+	scales := []struct {
+		value int64
+		name  string
+	}{
+		{1e9, "billion"},
+		{1e6, "million"},
+		{1e3, "thousand"},
+	}
+	for _, s := range scales {
+		if count := n / s.value; count > 0 {
+			words = append(words, enTriplet(count), s.name)
+			n %= s.value
+		}
+	}
+	if n > 0 {
+		words = append(words, enTriplet(n))
+	}
+	return strings.Join(words, " ")
+}
+
+func enTriplet(n int64) string {
+	var words []string
+	if h := n / 100; h > 0 {
+		words = append(words, enOnes[h], "hundred")
+	}
+	switch r := n % 100; {
+	case r == 0:
+	case r < 20:
+		words = append(words, enOnes[r])
+	case r%10 == 0:
+		words = append(words, enTens[r/10])
+	default:
+		words = append(words, enTens[r/10]+"-"+enOnes[r%10])
+	}
+	return strings.Join(words, " ")
+}
+
+// enCount — число со словом в нужном числе: one dollar, two dollars
+func enCount(n int64, word string) string {
+	if n == 1 {
+		return "one " + word
+	}
+	return enCardinal(n) + " " + word + "s"
+}
+
+// enOrdinal: меняется только последнее слово ("twenty-one" → "twenty-first")
+func enOrdinal(n int64) string {
+	words := enCardinal(n)
+	cut := strings.LastIndexAny(words, " -") + 1
+	last := words[cut:]
+	switch {
+	case enOrdinalIrregular[last] != "":
+		last = enOrdinalIrregular[last]
+	case strings.HasSuffix(last, "y"):
+		last = strings.TrimSuffix(last, "y") + "ieth"
+	default:
+		last += "th"
+	}
+	return words[:cut] + last
+}
+
+// enYear читает год парами цифр: 1812 → eighteen twelve, 1900 → nineteen hundred
+func enYear(year int64) string {
+	switch {
+	case year < 1000 || year >= 10000:
+		return enCardinal(year)
+	case year >= 2000 && year < 2010, year%1000 == 0:
+		return enCardinal(year)
+	}
+	high, low := year/100, year%100
+	switch {
+	case low == 0:
+		return enCardinal(high) + " hundred"
+	case low < 10:
+		return enCardinal(high) + " oh " + enOnes[low]
+	default:
+		return enCardinal(high) + " " + enCardinal(low)
+	}
+}
+
+func enDecimal(s string) string {
+	whole, frac, ok := strings.Cut(s, ".")
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return s
+	}
+	if !ok {
+		return enCardinal(n)
+	}
+	return enCardinal(n) + " point " + enDigits(frac)
+}
+
+func enDigits(s string) string {
+	var words []string
+	for _, d := range s {
+		words = append(words, enOnes[d-'0'])
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,103 @@
+package normalize
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type Lang int
+
+const (
+	Russian Lang = iota
+	English
+)
+
+var (
+	urlRe         = regexp.MustCompile(`(?i)(https?://|www\.)[^\s<>"«»]*[^\s<>"«».,;:!?)]`)
+	footnoteRe    = regexp.MustCompile(`\[(\d{1,3}|\*+)\]|\{\d{1,3}\}|[¹²³⁰⁴⁵⁶⁷⁸⁹]+`)
+	starNoteRe    = regexp.MustCompile(`([\p{L}.,;:!?»"])\*+`)
+	spacesRe      = regexp.MustCompile(`[ \t]{2,}`)
+	spaceBeforeRe = regexp.MustCompile(` +([.,;:!?])`)
+	// минус перед числом, но не дефис диапазона ("10-15") и не тире реплики ("- 5 минут")
+	minusRe = regexp.MustCompile(`(?m)(^|[\s(\[«"])[-−](\d)`)
+)
+
+// Text готовит текст страницы к синтезу: убирает ссылки и сноски, раскрывает
+// сокращения, римские цифры в заголовках и числа словами.
+func Text(text string) string {
+	return TextLang(text, Detect(text))
+}
+
+// TextLang — то же, что Text, но язык задаётся явно
+func TextLang(text string, lang Lang) string {
+	text = urlRe.ReplaceAllString(text, "")
+	text = footnoteRe.ReplaceAllString(text, "")
+	text = starNoteRe.ReplaceAllString(text, "$1")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = romanInHeading(line, lang)
+	}
+	text = strings.Join(lines, "\n")
+
+	// числа раньше сокращений: единицы после чисел ("5 млн") согласуются с числом
+	switch lang {
+	case English:
+		text = enNumbers(text)
+		text = expandAbbreviations(text, enAbbreviations)
+	default:
+		text = ruNumbers(text)
+		text = expandAbbreviations(text, ruAbbreviations)
+	}
+
+	text = spacesRe.ReplaceAllString(text, " ")
+	text = spaceBeforeRe.ReplaceAllString(text, "$1")
+	return text
+}
+
+// Detect определяет язык по преобладающему алфавиту
+func Detect(text string) Lang {
+	var cyr, lat int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyr++
+		case unicode.Is(unicode.Latin, r):
+			lat++
+		}
+	}
+	if lat > cyr {
+		return English
+	}
+	return Russian
+}
+
+// replaceSubmatch — ReplaceAllStringFunc с доступом к группам
+func replaceSubmatch(re *regexp.Regexp, s string, fn func(m []string) string) string {
+	return replaceSubmatchNext(re, s, func(m []string, _ string) string { return fn(m) })
+}
+
+// replaceSubmatchNext — то же, что replaceSubmatch, но fn видит и текст после совпадения
+func replaceSubmatchNext(re *regexp.Regexp, s string, fn func(m []string, next string) string) string {
+	idx := re.FindAllStringSubmatchIndex(s, -1)
+	if idx == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range idx {
+		m := make([]string, len(loc)/2)
+		for i := range m {
+			if loc[2*i] >= 0 {
+				m[i] = s[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(fn(m, s[loc[1]:]))
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
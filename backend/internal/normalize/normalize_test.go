@@ -0,0 +1,67 @@
+package normalize
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "перезаписать golden-файлы в testdata")
+
+// TestGolden сверяет нормализацию testdata/<язык>.txt с testdata/<язык>.golden.
+// После намеренного изменения правил: go test ./internal/normalize -update
+func TestGolden(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		lang Lang
+	}{
+		{"ru", Russian},
+		{"en", English},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in, err := os.ReadFile(filepath.Join("testdata", tc.name+".txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := TextLang(string(in), tc.lang)
+
+			golden := filepath.Join("testdata", tc.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			inLines := strings.Split(string(in), "\n")
+			gotLines := strings.Split(got, "\n")
+			wantLines := strings.Split(string(want), "\n")
+			if len(gotLines) != len(wantLines) {
+				t.Fatalf("got %d lines, want %d", len(gotLines), len(wantLines))
+			}
+			for i := range wantLines {
+				if gotLines[i] != wantLines[i] {
+					t.Errorf("line %d %q:\n got: %q\nwant: %q", i+1, inLines[i], gotLines[i], wantLines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for text, want := range map[string]Lang{
+		"Глава первая":          Russian,
+		"Chapter one":           English,
+		"В 1812 году, in 1812.": Russian,
+		"":                      Russian,
+	} {
+		if got := Detect(text); got != want {
+			t.Errorf("Detect(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
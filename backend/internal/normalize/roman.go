@@ -0,0 +1,74 @@
+package normalize
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	romanRe          = regexp.MustCompile(`^M{0,3}(CM|CD|D?C{0,3})(XC|XL|L?X{0,3})(IX|IV|V?I{0,3})$`)
+	headingRomanRe   = regexp.MustCompile(`(?i)^(\s*)(глава|часть|книга|том|chapter|part|book|volume)(\s+)([IVXLCDM]+)(\W|$)`)
+	loneRomanHeading = regexp.MustCompile(`^(\s*)([IVXL]+)(\.?)(\s*)$`)
+)
+
+// самый большой номер главы, который читается без слова "глава"
+const maxLoneRoman = 50
+
+var romanValues = map[byte]int64{'I': 1, 'V': 5, 'X': 10, 'L': 50, 'C': 100, 'D': 500, 'M': 1000}
+
+// род слов, после которых номер читается порядковым числительным ("Глава II" → "Глава вторая")
+var ruHeadingGender = map[string]gender{
+	"глава": fem, "часть": fem, "книга": fem, "том": masc,
+}
+
+// romanToInt переводит римское число в арабское; 0 — если строка не римское число
+func romanToInt(s string) int64 {
+	s = strings.ToUpper(s)
+	if s == "" || !romanRe.MatchString(s) {
+		return 0
+	}
+	var n int64
+	for i := 0; i < len(s); i++ {
+		v := romanValues[s[i]]
+		if i+1 < len(s) && v < romanValues[s[i+1]] {
+			n -= v
+		} else {
+			n += v
+		}
+	}
+	return n
+}
+
+// romanInHeading заменяет римский номер в заголовке главы словами
+func romanInHeading(line string, lang Lang) string {
+	if m := headingRomanRe.FindStringSubmatch(line); m != nil {
+		n := romanToInt(m[4])
+		if n == 0 {
+			return line
+		}
+		var num string
+		if g, ok := ruHeadingGender[strings.ToLower(m[2])]; ok {
+			num = ruOrdinal(n, g, nom)
+		} else if lang == English {
+			num = enCardinal(n)
+		} else {
+			num = strconv.FormatInt(n, 10)
+		}
+		return m[1] + m[2] + m[3] + num + m[5] + line[len(m[0]):]
+	}
+
+	// строка из одного римского числа — номер главы без слова "глава". Номера глав
+	// небольшие, поэтому C, D и M не берутся, а одна буква ("I", "V") — только с точкой
+	if m := loneRomanHeading.FindStringSubmatch(line); m != nil {
+		n := romanToInt(m[2])
+		if n == 0 || n > maxLoneRoman || (len(m[2]) == 1 && m[3] == "") {
+			return line
+		}
+		if lang == English {
+			return m[1] + enCardinal(n) + m[3] + m[4]
+		}
+		return m[1] + ruCardinal(n, masc, nom) + m[3] + m[4]
+	}
+	return line
+}
@@ -0,0 +1,722 @@
+package normalize
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type gcase int
+
+const (
+	nom gcase = iota
+	gen
+	dat
+	ins
+	prep
+	acc
+)
+
+type gender int
+
+const (
+	masc gender = iota
+	fem
+	neut
+)
+
+// формы количественных числительных: им., род., дат., твор., предл.
+var ruUnits = [20][5]string{
+	{"ноль", "нуля", "нулю", "нулём", "нуле"},
+	{"один", "одного", "одному", "одним", "одном"},
+	{"два", "двух", "двум", "двумя", "двух"},
+	{"три", "трёх", "трём", "тремя", "трёх"},
+	{"четыре", "четырёх", "четырём", "четырьмя", "четырёх"},
+	{"пять", "пяти", "пяти", "пятью", "пяти"},
+	{"шесть", "шести", "шести", "шестью", "шести"},
+	{"семь", "семи", "семи", "семью", "семи"},
+	{"восемь", "восьми", "восьми", "восемью", "восьми"},
+	{"девять", "девяти", "девяти", "девятью", "девяти"},
+	{"десять", "десяти", "десяти", "десятью", "десяти"},
+	{"одиннадцать", "одиннадцати", "одиннадцати", "одиннадцатью", "одиннадцати"},
+	{"двенадцать", "двенадцати", "двенадцати", "двенадцатью", "двенадцати"},
+	{"тринадцать", "тринадцати", "тринадцати", "тринадцатью", "тринадцати"},
+	{"четырнадцать", "четырнадцати", "четырнадцати", "четырнадцатью", "четырнадцати"},
+	{"пятнадцать", "пятнадцати", "пятнадцати", "пятнадцатью", "пятнадцати"},
+	{"шестнадцать", "шестнадцати", "шестнадцати", "шестнадцатью", "шестнадцати"},
+	{"семнадцать", "семнадцати", "семнадцати", "семнадцатью", "семнадцати"},
+	{"восемнадцать", "восемнадцати", "восемнадцати", "восемнадцатью", "восемнадцати"},
+	{"девятнадцать", "девятнадцати", "девятнадцати", "девятнадцатью", "девятнадцати"},
+}
+
+var ruTens = [10][5]string{
+	{},
+	{},
+	{"двадцать", "двадцати", "двадцати", "двадцатью", "двадцати"},
+	{"тридцать", "тридцати", "тридцати", "тридцатью", "тридцати"},
+	{"сорок", "сорока", "сорока", "сорока", "сорока"},
+	{"пятьдесят", "пятидесяти", "пятидесяти", "пятьюдесятью", "пятидесяти"},
+	{"шестьдесят", "шестидесяти", "шестидесяти", "шестьюдесятью", "шестидесяти"},
+	{"семьдесят", "семидесяти", "семидесяти", "семьюдесятью", "семидесяти"},
+	{"восемьдесят", "восьмидесяти", "восьмидесяти", "восемьюдесятью", "восьмидесяти"},
+	{"девяносто", "девяноста", "девяноста", "девяноста", "девяноста"},
+}
+
+var ruHundreds = [10][5]string{
+	{},
+	{"сто", "ста", "ста", "ста", "ста"},
+	{"двести", "двухсот", "двумстам", "двумястами", "двухстах"},
+	{"триста", "трёхсот", "трёмстам", "тремястами", "трёхстах"},
+	{"четыреста", "четырёхсот", "четырёмстам", "четырьмястами", "четырёхстах"},
+	{"пятьсот", "пятисот", "пятистам", "пятьюстами", "пятистах"},
+	{"шестьсот", "шестисот", "шестистам", "шестьюстами", "шестистах"},
+	{"семьсот", "семисот", "семистам", "семьюстами", "семистах"},
+	{"восемьсот", "восьмисот", "восьмистам", "восемьюстами", "восьмистах"},
+	{"девятьсот", "девятисот", "девятистам", "девятьюстами", "девятистах"},
+}
+
+// noun — существительное при числе: единственное и множественное число по падежам
+type noun struct {
+	g    gender
+	sing [5]string
+	pl   [5]string
+}
+
+var (
+	ruThousand = noun{fem,
+		[5]string{"тысяча", "тысячи", "тысяче", "тысячей", "тысяче"},
+		[5]string{"тысячи", "тысяч", "тысячам", "тысячами", "тысячах"}}
+	ruMillion = noun{masc,
+		[5]string{"миллион", "миллиона", "миллиону", "миллионом", "миллионе"},
+		[5]string{"миллионы", "миллионов", "миллионам", "миллионами", "миллионах"}}
+	ruBillion = noun{masc,
+		[5]string{"миллиард", "миллиарда", "миллиарду", "миллиардом", "миллиарде"},
+		[5]string{"миллиарды", "миллиардов", "миллиардам", "миллиардами", "миллиардах"}}
+)
+
+// единицы измерения, которые согласуются с числом перед ними
+var ruUnitNouns = map[string]noun{
+	"%": {masc,
+		[5]string{"процент", "процента", "проценту", "процентом", "проценте"},
+		[5]string{"проценты", "процентов", "процентам", "процентами", "процентах"}},
+	"тыс.": ruThousand,
+	"млн":  ruMillion,
+	"млрд": ruBillion,
+	"руб.": {masc,
+		[5]string{"рубль", "рубля", "рублю", "рублём", "рубле"},
+		[5]string{"рубли", "рублей", "рублям", "рублями", "рублях"}},
+	"коп.": {fem,
+		[5]string{"копейка", "копейки", "копейке", "копейкой", "копейке"},
+		[5]string{"копейки", "копеек", "копейкам", "копейками", "копейках"}},
+	"км": {masc,
+		[5]string{"километр", "километра", "километру", "километром", "километре"},
+		[5]string{"километры", "километров", "километрам", "километрами", "километрах"}},
+	"кг": {masc,
+		[5]string{"килограмм", "килограмма", "килограмму", "килограммом", "килограмме"},
+		[5]string{"килограммы", "килограммов", "килограммам", "килограммами", "килограммах"}},
+	"м": {masc,
+		[5]string{"метр", "метра", "метру", "метром", "метре"},
+		[5]string{"метры", "метров", "метрам", "метрами", "метрах"}},
+	"$": {masc,
+		[5]string{"доллар", "доллара", "доллару", "долларом", "долларе"},
+		[5]string{"доллары", "долларов", "долларам", "долларами", "долларах"}},
+	"см": {masc,
+		[5]string{"сантиметр", "сантиметра", "сантиметру", "сантиметром", "сантиметре"},
+		[5]string{"сантиметры", "сантиметров", "сантиметрам", "сантиметрами", "сантиметрах"}},
+}
+
+var ruCent = noun{masc,
+	[5]string{"цент", "цента", "центу", "центом", "центе"},
+	[5]string{"центы", "центов", "центам", "центами", "центах"}}
+
+var ruMonthsGen = []string{
+	"января", "февраля", "марта", "апреля", "мая", "июня",
+	"июля", "августа", "сентября", "октября", "ноября", "декабря",
+}
+
+// падеж, которого требует предлог перед числом; "в", "на", "с", "за" неоднозначны
+var ruPrepositionCase = map[string]gcase{
+	"до": gen, "от": gen, "из": gen, "без": gen, "около": gen, "после": gen,
+	"для": gen, "у": gen, "кроме": gen, "среди": gen, "вокруг": gen,
+	"к": dat, "ко": dat, "согласно": dat,
+	"о": prep, "об": prep, "при": prep,
+	"между": ins, "над": ins, "под": ins, "перед": ins,
+}
+
+// основы порядковых числительных; ordStressed — окончание "-ой" в им. п. м. р.
+var ruOrdinalStems = map[int64]string{
+	1: "перв", 2: "втор", 3: "трет", 4: "четвёрт", 5: "пят", 6: "шест", 7: "седьм",
+	8: "восьм", 9: "девят", 10: "десят", 11: "одиннадцат", 12: "двенадцат",
+	13: "тринадцат", 14: "четырнадцат", 15: "пятнадцат", 16: "шестнадцат",
+	17: "семнадцат", 18: "восемнадцат", 19: "девятнадцат", 20: "двадцат",
+	30: "тридцат", 40: "сороков", 50: "пятидесят", 60: "шестидесят",
+	70: "семидесят", 80: "восьмидесят", 90: "девяност", 100: "сот",
+	200: "двухсот", 300: "трёхсот", 400: "четырёхсот", 500: "пятисот",
+	600: "шестисот", 700: "семисот", 800: "восьмисот", 900: "девятисот",
+}
+
+var ordStressed = map[int64]bool{2: true, 6: true, 7: true, 8: true, 40: true}
+
+// сокращения единиц измерения из ruUnitNouns; "млн" раньше "м", иначе не совпадёт целиком
+const ruUnitPattern = `(%|тыс\.|млн|млрд|руб\.|коп\.|км|кг|см|м)`
+
+var (
+	ruNumberSignRe = regexp.MustCompile(`№\s*`)
+	// № в начале предложения или реплики читается с заглавной
+	ruNumberSignStartRe = regexp.MustCompile(`(?m)(^[ \t]*(?:[—–][ \t]*)?|[.!?…][ \t]+)№\s*`)
+	ruTimeRe            = regexp.MustCompile(`(?m)(^|[^\p{L}\p{N}.,:])(?:(\p{L}+)\s+)?([01]?\d|2[0-3]):([0-5]\d)($|[^\p{N}:])`)
+	ruYearRangeRe       = regexp.MustCompile(`(?m)(^|[^\p{L}\p{N}])(?:(\p{L}+)\s+)?(\d{3,4})\s*[-–—]\s*(\d{2}|\d{4})\s*(гг\.|годы|годов|годам|годами|годах)($|[^\p{L}])`)
+	// "1,2,3" — перечисление: после запятых нужен пробел, иначе это прочтётся как дробь
+	ruNumberListRe = regexp.MustCompile(`\d+(?:,\d+){2,}`)
+	ruDateRe       = regexp.MustCompile(`(?m)(^|[^\p{L}\p{N}])((?i:к)\s+)?(\d{1,2})\.(\d{1,2})\.(\d{4})(?:\s*г\.)?($|[^\p{N}])`)
+	ruDayMonthRe   = regexp.MustCompile(`(?m)(^|[^\p{L}\p{N}])((?i:к)\s+)?(\d{1,2})\s+(` + strings.Join(ruMonthsGen, "|") + `)($|[^\p{L}])`)
+	ruYearRe       = regexp.MustCompile(`(?m)(^|[^\p{L}\p{N}])(?:(\p{L}+)\s+)?(\d{1,4})\s*(г\.|году|года|годом|год)($|[^\p{L}])`)
+	ruCenturyRe    = regexp.MustCompile(`(?m)(^|[^\p{L}])(?:(\p{L}+)\s+)?([IVXLC]+)\s*(в\.|веке|века|веку|веком|век)($|[^\p{L}])`)
+	ruOrdSuffixRe  = regexp.MustCompile(`(\d+)-(го|му|м|й|я|е|ю|ой)($|[^\p{L}])`)
+	ruDollarRe     = regexp.MustCompile(`\$\s?(\d{1,3}(?:[  ]\d{3})+|\d+)(?:[.,](\d{1,2}))?`)
+	ruDecimalRe    = regexp.MustCompile(`(?m)(^|[^\p{L}\p{N}.,])(?:(\p{L}+)\s+)?(\d+)[.,](\d+)(?:\s*` + ruUnitPattern + `($|[^\p{L}])|($|[^\p{N}.,]|[.,]$|[.,][^\p{N}]))`)
+	ruUnitRe       = regexp.MustCompile(`(?m)(^|[^\p{L}\p{N}])(?:(\p{L}+)\s+)?(\d{1,3}(?:[  ]\d{3})+|\d+)\s*` + ruUnitPattern + `($|[^\p{L}])`)
+	ruNumberRe     = regexp.MustCompile(`(?m)(^|[^\p{L}\p{N}])(?:(\p{L}+)\s+)?(\d{1,3}(?:[  ]\d{3})+|\d+)($|[^\p{N}])`)
+	ruGroupSepRe   = regexp.MustCompile(`[  ]`)
+	ruNextWordRe   = regexp.MustCompile(`^[ \t  ]+(\p{L}+)`)
+)
+
+func ruNumbers(text string) string {
+	text = ruNumberSignStartRe.ReplaceAllString(text, "${1}Номер ")
+	text = ruNumberSignRe.ReplaceAllString(text, "номер ")
+	text = ruNumberListRe.ReplaceAllStringFunc(text, func(s string) string {
+		return strings.ReplaceAll(s, ",", ", ")
+	})
+
+	// 05.03.1812 → пятого марта тысяча восемьсот двенадцатого года
+	text = replaceSubmatch(ruDateRe, text, func(m []string) string {
+		day, _ := strconv.ParseInt(m[3], 10, 64)
+		month, _ := strconv.Atoi(m[4])
+		year, _ := strconv.ParseInt(m[5], 10, 64)
+		if day < 1 || day > 31 || month < 1 || month > 12 {
+			return m[0]
+		}
+		c := gen
+		if m[2] != "" {
+			c = dat
+		}
+		return m[1] + m[2] + ruOrdinal(day, neut, c) + " " + ruMonthsGen[month-1] + " " +
+			ruOrdinal(year, masc, gen) + " года" + m[6]
+	})
+
+	// 5 мая → пятого мая
+	text = replaceSubmatch(ruDayMonthRe, text, func(m []string) string {
+		day, _ := strconv.ParseInt(m[3], 10, 64)
+		if day < 1 || day > 31 {
+			return m[0]
+		}
+		c := gen
+		if m[2] != "" {
+			c = dat
+		}
+		return m[1] + m[2] + ruOrdinal(day, neut, c) + " " + m[4] + m[5]
+	})
+
+	// в 10:30 → в десять тридцать, до 9:05 → до девяти ноль пяти
+	text = replaceSubmatch(ruTimeRe, text, func(m []string) string {
+		hour, _ := strconv.ParseInt(m[3], 10, 64)
+		minute, _ := strconv.ParseInt(m[4], 10, 64)
+		c := prepositionCase(m[2])
+		var min string
+		switch {
+		case minute == 0:
+			min = "ноль-ноль"
+		case minute < 10:
+			min = "ноль " + ruCardinal(minute, fem, c)
+		default:
+			min = ruCardinal(minute, fem, c)
+		}
+		return m[1] + withWord(m[2]) + ruCardinal(hour, masc, c) + " " + min + m[5]
+	})
+
+	// 1941-45 гг. → тысяча девятьсот сорок первый — сорок пятый годы
+	text = replaceSubmatchNext(ruYearRangeRe, text, func(m []string, next string) string {
+		from, _ := strconv.ParseInt(m[3], 10, 64)
+		to, _ := strconv.ParseInt(m[4], 10, 64)
+		if len(m[4]) == 2 {
+			to += from / 100 * 100
+			if to < from {
+				to += 100
+			}
+		}
+		if to <= from {
+			return m[0]
+		}
+		word := m[5]
+		c := map[string]gcase{"годы": nom, "годов": gen, "годам": dat, "годами": ins, "годах": prep}[word]
+		if word == "гг." {
+			c = prepositionCase(m[2])
+			if prev := strings.ToLower(m[2]); prev == "в" || prev == "во" {
+				c = prep
+			}
+			word = map[gcase]string{nom: "годы", gen: "годов", dat: "годам", ins: "годами", prep: "годах"}[c]
+			if endsSentence(m[6], next) {
+				word += "."
+			}
+		}
+		return m[1] + withWord(m[2]) + ruOrdinal(from, masc, c) + " — " + ruOrdinal(to, masc, c) + " " + word + m[6]
+	})
+
+	// минус — после диапазонов лет: "1999 - 2000 гг." не отрицательное число
+	text = minusRe.ReplaceAllString(text, "${1}минус $2")
+
+	// в 1812 году → в тысяча восемьсот двенадцатом году
+	text = replaceSubmatchNext(ruYearRe, text, func(m []string, next string) string {
+		year, _ := strconv.ParseInt(m[3], 10, 64)
+		word := m[4]
+		prev := strings.ToLower(m[2])
+		var c gcase
+		switch word {
+		case "году":
+			c = prep
+			if prev == "к" || prev == "ко" {
+				c = dat
+			}
+		case "года":
+			c = gen
+		case "годом":
+			c = ins
+		case "год":
+			c = nom
+		default: // г.
+			c = gen
+			word = "года"
+			if prev == "в" || prev == "во" {
+				c = prep
+				word = "году"
+			}
+			if endsSentence(m[5], next) {
+				word += "."
+			}
+		}
+		return m[1] + withWord(m[2]) + ruOrdinal(year, masc, c) + " " + word + m[5]
+	})
+
+	// XIX век → девятнадцатый век
+	text = replaceSubmatch(ruCenturyRe, text, func(m []string) string {
+		n := romanToInt(m[3])
+		if n == 0 {
+			return m[0]
+		}
+		word := m[4]
+		c := map[string]gcase{"век": nom, "века": gen, "веку": dat, "веком": ins, "веке": prep, "в.": nom}[word]
+		if word == "в." {
+			word = "век"
+			// в XIX в. → в девятнадцатом веке
+			if prev := strings.ToLower(m[2]); prev == "в" || prev == "во" {
+				c, word = prep, "веке"
+			}
+		}
+		return m[1] + withWord(m[2]) + ruOrdinal(n, masc, c) + " " + word + m[5]
+	})
+
+	// 5-го, 1-й, 2-я
+	text = replaceSubmatch(ruOrdSuffixRe, text, func(m []string) string {
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return m[0]
+		}
+		g, c := masc, nom
+		switch m[2] {
+		case "го":
+			c = gen
+		case "му":
+			c = dat
+		case "м":
+			c = prep
+		case "я":
+			g = fem
+		case "е":
+			g = neut
+		case "ю":
+			g, c = fem, acc
+		case "ой":
+			g, c = fem, gen
+		}
+		return ruOrdinal(n, g, c) + m[3]
+	})
+
+	// $3.50 → три доллара пятьдесят центов
+	text = replaceSubmatch(ruDollarRe, text, func(m []string) string {
+		n, err := strconv.ParseInt(ruGroupSepRe.ReplaceAllString(m[1], ""), 10, 64)
+		if err != nil {
+			return m[0]
+		}
+		var cents int64
+		if m[2] != "" {
+			cents, _ = strconv.ParseInt(m[2], 10, 64)
+			if len(m[2]) == 1 {
+				cents *= 10
+			}
+		}
+		dollars := ruCardinal(n, masc, nom) + " " + ruUnitNouns["$"].agree(n, nom)
+		switch {
+		case cents == 0:
+			return dollars
+		case n == 0:
+			return ruCardinal(cents, masc, nom) + " " + ruCent.agree(cents, nom)
+		default:
+			return dollars + " " + ruCardinal(cents, masc, nom) + " " + ruCent.agree(cents, nom)
+		}
+	})
+
+	// 3,14 и 3.14 → три целых четырнадцать сотых; после дроби единица всегда в род. п. ед. ч.
+	// "1.2.3" — не дробь
+	text = replaceSubmatch(ruDecimalRe, text, func(m []string) string {
+		num, ok := ruDecimal(m[3], m[4], prepositionCase(m[2]))
+		if !ok {
+			return m[0]
+		}
+		if m[5] != "" {
+			return m[1] + withWord(m[2]) + num + " " + ruUnitNouns[m[5]].sing[gen] + m[6]
+		}
+		return m[1] + withWord(m[2]) + num + m[7]
+	})
+
+	// 5 %, 3 млн, 10 км
+	// два прохода, как и для чисел ниже: "1 руб. 50 коп."
+	for i := 0; i < 2; i++ {
+		text = replaceSubmatchNext(ruUnitRe, text, func(m []string, next string) string {
+			n, err := strconv.ParseInt(ruGroupSepRe.ReplaceAllString(m[3], ""), 10, 64)
+			if err != nil {
+				return m[0]
+			}
+			c := prepositionCase(m[2])
+			u := ruUnitNouns[m[4]]
+			word := u.agree(n, c)
+			// точка сокращения в конце предложения — она же и конец предложения
+			if strings.HasSuffix(m[4], ".") && endsSentence(m[5], next) {
+				word += "."
+			}
+			return m[1] + withWord(m[2]) + ruCardinal(n, u.g, c) + " " + word + m[5]
+		})
+	}
+
+	// два прохода: граничный символ одного совпадения мог быть началом следующего
+	for i := 0; i < 2; i++ {
+		text = replaceSubmatchNext(ruNumberRe, text, func(m []string, next string) string {
+			n, err := strconv.ParseInt(ruGroupSepRe.ReplaceAllString(m[3], ""), 10, 64)
+			if err != nil {
+				return m[0]
+			}
+			g, c := masc, prepositionCase(m[2])
+			if w := ruNextWordRe.FindStringSubmatch(m[4] + next); w != nil {
+				g, c = nounAgreement(strings.ToLower(w[1]), n, c)
+			}
+			return m[1] + withWord(m[2]) + ruCardinal(n, g, c) + m[4]
+		})
+	}
+
+	return text
+}
+
+// endsSentence сообщает, что после символа tail и текста next начинается новое предложение
+func endsSentence(tail, next string) bool {
+	if tail == "" || tail == "\n" {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(strings.TrimLeft(next, " "))
+	return tail == " " && unicode.IsUpper(r)
+}
+
+func withWord(w string) string {
+	if w == "" {
+		return ""
+	}
+	return w + " "
+}
+
+func prepositionCase(word string) gcase {
+	if c, ok := ruPrepositionCase[strings.ToLower(word)]; ok {
+		return c
+	}
+	return nom
+}
+
+// слова, которые часто стоят после числа, но по окончанию похожи на существительные
+var ruNotNouns = map[string]bool{
+	"или": true, "либо": true, "для": true, "после": true, "около": true, "через": true,
+	"когда": true, "тогда": true, "где": true, "куда": true, "тоже": true, "также": true,
+	"уже": true, "ещё": true, "еще": true, "это": true, "что": true, "все": true, "всё": true,
+}
+
+// nounAgreement угадывает по окончанию существительного после числа n, в каком роде
+// и падеже читать число: "2 книги" → "две", "21 книгу" → "двадцать одну".
+// Род различается только у чисел на 1 и 2, поэтому для остальных остаётся мужской.
+func nounAgreement(word string, n int64, c gcase) (gender, gcase) {
+	if utf8.RuneCountInString(word) < 3 || ruNotNouns[word] || n%100 >= 11 && n%100 <= 14 {
+		return masc, c
+	}
+	endsWith := func(suffixes ...string) bool {
+		for _, s := range suffixes {
+			if strings.HasSuffix(word, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	last := n % 10
+	// без предлога падеж виден по окончанию множественного числа: "3 часами"
+	if c == nom && last != 1 {
+		switch {
+		case endsWith("ами", "ями"):
+			c = ins
+		case endsWith("ах", "ях"):
+			c = prep
+		}
+	}
+
+	switch {
+	case last == 1 && c == nom:
+		switch {
+		case endsWith("а", "я"):
+			return fem, nom
+		case endsWith("у", "ю"):
+			return fem, acc
+		case endsWith("о", "е", "ё"):
+			return neut, nom
+		}
+	case last == 1 && (c == gen || c == prep):
+		if endsWith("ы", "и") {
+			return fem, c
+		}
+	case last == 1 && c == dat:
+		if endsWith("е", "и") {
+			return fem, c
+		}
+	case last == 1 && c == ins:
+		if endsWith("ой", "ей", "ою", "ею", "ью") {
+			return fem, c
+		}
+	case last >= 2 && last <= 4 && c == nom:
+		if endsWith("ы", "и") {
+			return fem, c
+		}
+	}
+	return masc, c
+}
+
+// знаменатели десятичных дробей по числу знаков после запятой
+var ruFractionStems = []string{"", "десят", "сот", "тысячн", "десятитысячн", "стотысячн", "миллионн"}
+
+// ruDecimal читает десятичную дробь: 3,14 → три целых четырнадцать сотых
+func ruDecimal(whole, frac string, c gcase) (string, bool) {
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || len(frac) >= len(ruFractionStems) {
+		return "", false
+	}
+	f, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return "", false
+	}
+	return ruCardinal(w, fem, c) + " " + ruFraction("цел", w, c) + " " +
+		ruCardinal(f, fem, c) + " " + ruFraction(ruFractionStems[len(frac)], f, c), true
+}
+
+// ruFraction согласует с числом n слово-прилагательное женского рода: целая, десятых
+func ruFraction(stem string, n int64, c gcase) string {
+	sing := [...]string{"ая", "ой", "ой", "ой", "ой", "ую"}
+	pl := [...]string{"ые", "ых", "ым", "ыми", "ых", "ые"}
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return stem + sing[c]
+	case c == nom || c == acc:
+		// "две целых", "пять десятых"
+		return stem + pl[gen]
+	default:
+		return stem + pl[c]
+	}
+}
+
+// agree выбирает форму существительного после числа n в падеже c
+func (u noun) agree(n int64, c gcase) string {
+	if c == acc {
+		c = nom
+	}
+	last2 := n % 100
+	last := n % 10
+	if c == nom {
+		switch {
+		case last2 >= 11 && last2 <= 14:
+			return u.pl[gen]
+		case last == 1:
+			return u.sing[nom]
+		case last >= 2 && last <= 4:
+			return u.sing[gen]
+		default:
+			return u.pl[gen]
+		}
+	}
+	if last == 1 && last2 != 11 {
+		return u.sing[c]
+	}
+	return u.pl[c]
+}
+
+// ruCardinal записывает количественное числительное словами
+func ruCardinal(n int64, g gender, c gcase) string {
+	if c == acc {
+		c = nom
+		// одну, но "два/две" в винительном для неодушевлённых совпадает с именительным
+		if g == fem && n%10 == 1 && n%100 != 11 {
+			return strings.TrimSuffix(ruCardinal(n, g, nom), "одна") + "одну"
+		}
+	}
+	if n == 0 {
+		return ruUnits[0][c]
+	}
+	if n < 0 {
+		return "минус " + ruCardinal(-n, g, c)
+	}
+	if n >= 1e12 {
+		return ruDigits(n)
+	}
+
+	var words []string
+	scales := []struct {
+		value int64
+		noun  noun
+	}{
+		{1e9, ruBillion},
+		{1e6, ruMillion},
+		{1e3, ruThousand},
+	}
+	for _, s := range scales {
+		if count := n / s.value; count > 0 {
+			// "тысяча", а не "одна тысяча"
+			if !(s.value == 1e3 && count == 1) {
+				words = append(words, ruTriplet(count, s.noun.g, c)...)
+			}
+			words = append(words, s.noun.agree(count, c))
+			n %= s.value
+		}
+	}
+	if n > 0 {
+		words = append(words, ruTriplet(n, g, c)...)
+	}
+	return strings.Join(words, " ")
+}
+
+func ruTriplet(n int64, g gender, c gcase) []string {
+	var words []string
+	if h := n / 100; h > 0 {
+		words = append(words, ruHundreds[h][c])
+	}
+	r := n % 100
+	if r >= 20 {
+		words = append(words, ruTens[r/10][c])
+		r %= 10
+	}
+	if r > 0 {
+		words = append(words, ruUnit(r, g, c))
+	}
+	return words
+}
+
+func ruUnit(n int64, g gender, c gcase) string {
+	if c == nom {
+		switch {
+		case n == 1 && g == fem:
+			return "одна"
+		case n == 1 && g == neut:
+			return "одно"
+		case n == 2 && g == fem:
+			return "две"
+		}
+	}
+	if n == 1 && g == fem {
+		return "одной"
+	}
+	return ruUnits[n][c]
+}
+
+// ruOrdinal записывает порядковое числительное: склоняется только последнее слово
+func ruOrdinal(n int64, g gender, c gcase) string {
+	if n <= 0 || n >= 1e6 {
+		return ruCardinal(n, g, c)
+	}
+
+	if n%1000 == 0 {
+		count := n / 1000
+		prefix := ""
+		switch {
+		case count == 1:
+		case count < 10:
+			prefix = ruUnits[count][gen]
+		default:
+			prefix = ruCardinal(count, fem, nom) + " "
+		}
+		return prefix + "тысячн" + ordEnding(false, false, g, c)
+	}
+
+	var words []string
+	if high := n / 1000 * 1000; high > 0 {
+		words = append(words, ruCardinal(high, masc, nom))
+	}
+
+	low := n % 1000
+	var last int64
+	switch r := low % 100; {
+	case r == 0:
+		last = low
+	case r < 20:
+		if h := low / 100; h > 0 {
+			words = append(words, ruHundreds[h][nom])
+		}
+		last = r
+	default:
+		if h := low / 100; h > 0 {
+			words = append(words, ruHundreds[h][nom])
+		}
+		if r%10 == 0 {
+			last = r
+		} else {
+			words = append(words, ruTens[r/10][nom])
+			last = r % 10
+		}
+	}
+
+	words = append(words, ruOrdinalStems[last]+ordEnding(ordStressed[last], last == 3, g, c))
+	return strings.Join(words, " ")
+}
+
+func ordEnding(stressed, soft bool, g gender, c gcase) string {
+	if soft {
+		switch g {
+		case fem:
+			return [...]string{"ья", "ьей", "ьей", "ьей", "ьей", "ью"}[c]
+		case neut:
+			return [...]string{"ье", "ьего", "ьему", "ьим", "ьем", "ье"}[c]
+		default:
+			return [...]string{"ий", "ьего", "ьему", "ьим", "ьем", "ий"}[c]
+		}
+	}
+	switch g {
+	case fem:
+		return [...]string{"ая", "ой", "ой", "ой", "ой", "ую"}[c]
+	case neut:
+		return [...]string{"ое", "ого", "ому", "ым", "ом", "ое"}[c]
+	default:
+		if stressed && (c == nom || c == acc) {
+			return "ой"
+		}
+		return [...]string{"ый", "ого", "ому", "ым", "ом", "ый"}[c]
+	}
+}
+
+// ruDigits читает слишком длинное число по цифрам
+func ruDigits(n int64) string {
+	var words []string
+	for _, d := range strconv.FormatInt(n, 10) {
+		words = append(words, ruUnits[d-'0'][nom])
+	}
+	return strings.Join(words, " ")
+}
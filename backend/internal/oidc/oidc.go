@@ -0,0 +1,110 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"voicebook/internal/config"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/oauth2"
+)
+
+// ErrNonce — id_token выдан не для этого входа (повтор или подмена ответа)
+var ErrNonce = errors.New("id token nonce mismatch")
+
+// Identity — пользователь по данным провайдера
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+}
+
+// Provider ведёт вход по authorization code с PKCE у одного провайдера
+type Provider struct {
+	oauth    oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+	client   *http.Client
+}
+
+// New читает discovery-документ провайдера, поэтому провайдер должен быть доступен при старте
+func New(ctx context.Context, cfg config.OIDC) (*Provider, error) {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+	ctx = gooidc.ClientContext(ctx, client)
+
+	p, err := gooidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover oidc provider: %w", err)
+	}
+
+	return &Provider{
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     p.Endpoint(),
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		},
+		verifier: p.Verifier(&gooidc.Config{ClientID: cfg.ClientID}),
+		client:   client,
+	}, nil
+}
+
+// AuthURL — адрес страницы входа провайдера; state, nonce и verifier
+// сервер хранит до возврата пользователя
+func (p *Provider) AuthURL(state, nonce, verifier string) string {
+	return p.oauth.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange меняет code на токены и проверяет id_token: подпись, издателя,
+// получателя, срок и nonce
+func (p *Provider) Exchange(ctx context.Context, code, nonce, verifier string) (Identity, error) {
+	ctx = gooidc.ClientContext(ctx, p.client)
+
+	tok, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	raw, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, errors.New("token response has no id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to verify id token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return Identity{}, ErrNonce
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("failed to parse id token claims: %w", err)
+	}
+
+	return Identity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Username:      claims.PreferredUsername,
+	}, nil
+}
+
+// NewVerifier — случайный code_verifier для PKCE
+func NewVerifier() string {
+	return oauth2.GenerateVerifier()
+}
@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"voicebook/internal/logging"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// из тела запроса входа читается не больше, чтобы достать логин
+const maxPeekBody = 64 << 10
+
+type Config struct {
+	PerIP    Rule
+	PerLogin Rule
+	Backoff  Backoff
+	// TrustProxy — брать адрес клиента из X-Forwarded-For (сервер стоит за прокси)
+	TrustProxy bool
+	// TrustedHops — сколько своих прокси дописывают адрес в X-Forwarded-For
+	TrustedHops int
+}
+
+// Guard собирает middleware ограничения частоты и блокировки входа.
+// onLimit пишет тело ответа 429; заголовок Retry-After выставляет сам Guard.
+type Guard struct {
+	store   Store
+	locks   Lockouts
+	cfg     Config
+	onLimit http.HandlerFunc
+}
+
+func New(store Store, locks Lockouts, cfg Config, onLimit http.HandlerFunc) *Guard {
+	return &Guard{store: store, locks: locks, cfg: cfg, onLimit: onLimit}
+}
+
+// PerIP ограничивает частоту запросов с одного адреса; scope разделяет лимиты разных эндпоинтов
+func (g *Guard) PerIP(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !g.take(w, r, scope+":ip:"+g.clientIP(r), g.cfg.PerIP) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PerLogin ограничивает частоту попыток для одного логина из тела запроса, с любых адресов
+func (g *Guard) PerLogin(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			login := peekLogin(r)
+			if login != "" && !g.take(w, r, scope+":login:"+login, g.cfg.PerLogin) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Lockout блокирует логин после серии неудачных входов. Исход входа определяется
+// по статусу ответа: 401 — неудача, 2xx — успех, сбрасывающий счётчик.
+func (g *Guard) Lockout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := peekLogin(r)
+		if login == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger := logging.FromContext(r.Context())
+
+		locked, err := g.locks.LockedFor(r.Context(), login)
+		if err != nil {
+			logger.Warn("lockout check failed, allowing request", "error", err)
+		} else if locked > 0 {
+			g.reject(w, r, locked)
+			return
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		switch status := ww.Status(); {
+		case status == http.StatusUnauthorized:
+			d, err := g.locks.Fail(r.Context(), login, g.cfg.Backoff)
+			if err != nil {
+				logger.Warn("failed to record login failure", "error", err)
+			} else if d > 0 {
+				logger.Warn("login locked after repeated failures", "locked_login", login, "duration", d)
+			}
+		case status >= 200 && status < 300:
+			if err := g.locks.Reset(r.Context(), login); err != nil {
+				logger.Warn("failed to reset login failures", "error", err)
+			}
+		}
+	})
+}
+
+// take при сбое хранилища пропускает запрос: недоступная база не должна закрывать вход
+func (g *Guard) take(w http.ResponseWriter, r *http.Request, key string, rule Rule) bool {
+	allowed, wait, err := g.store.Take(r.Context(), key, rule)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("rate limit check failed, allowing request", "error", err)
+		return true
+	}
+	if !allowed {
+		g.reject(w, r, wait)
+	}
+	return allowed
+}
+
+func (g *Guard) reject(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+	g.onLimit(w, r)
+}
+
+// clientIP берёт адрес, который дописал в X-Forwarded-For самый внешний свой прокси:
+// записи левее него присылает клиент, и подменой их обходились бы лимиты по адресу
+func (g *Guard) clientIP(r *http.Request) string {
+	if g.cfg.TrustProxy {
+		var hops []string
+		for _, h := range r.Header.Values("X-Forwarded-For") {
+			for _, addr := range strings.Split(h, ",") {
+				if addr = strings.TrimSpace(addr); addr != "" {
+					hops = append(hops, addr)
+				}
+			}
+		}
+		if len(hops) > 0 {
+			// цепочка короче числа прокси — все её записи добавлены своими прокси
+			return hops[max(len(hops)-max(g.cfg.TrustedHops, 1), 0)]
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// peekLogin достаёт логин из JSON-тела и возвращает тело на место для обработчика
+func peekLogin(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBody))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return ""
+	}
+
+	var req struct {
+		Login string `json:"login"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	// логины различают регистр, поэтому и ключ лимита берётся как есть
+	if strings.TrimSpace(req.Login) == "" {
+		return ""
+	}
+	return req.Login
+}
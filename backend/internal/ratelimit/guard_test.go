@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		trust bool
+		hops  int
+		xff   []string
+		want  string
+	}{
+		{"no proxy ignores header", false, 1, []string{"6.6.6.6"}, "10.0.0.1"},
+		{"no header", true, 1, nil, "10.0.0.1"},
+		{"one proxy", true, 1, []string{"1.1.1.1"}, "1.1.1.1"},
+		{"spoofed left entries", true, 1, []string{"6.6.6.6, 7.7.7.7, 1.1.1.1"}, "1.1.1.1"},
+		{"two proxies", true, 2, []string{"6.6.6.6, 1.1.1.1, 10.0.0.2"}, "1.1.1.1"},
+		{"repeated headers", true, 1, []string{"6.6.6.6", "1.1.1.1"}, "1.1.1.1"},
+		{"chain shorter than hops", true, 3, []string{"1.1.1.1, 10.0.0.2"}, "1.1.1.1"},
+		{"zero hops means one", true, 0, []string{"6.6.6.6, 1.1.1.1"}, "1.1.1.1"},
+		{"empty entries", true, 1, []string{"6.6.6.6, 1.1.1.1, "}, "1.1.1.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := New(NewMemory(), NewMemory(), Config{TrustProxy: tc.trust, TrustedHops: tc.hops}, nil)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.0.0.1:5555"
+			for _, v := range tc.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := g.clientIP(r); got != tc.want {
+				t.Errorf("clientIP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func tooMany(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+func TestPerIP(t *testing.T) {
+	g := New(NewMemory(), NewMemory(), Config{PerIP: Rule{PerMinute: 1, Burst: 2}}, tooMany)
+	h := g.PerIP("login")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.RemoteAddr = addr + ":1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if w := do("1.1.1.1"); w.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i+1, w.Code, want)
+		}
+	}
+	if w := do("1.1.1.1"); w.Header().Get("Retry-After") == "" {
+		t.Error("429 without Retry-After")
+	}
+	if w := do("2.2.2.2"); w.Code != http.StatusOK {
+		t.Errorf("other address: status %d, want 200", w.Code)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	mem := NewMemory()
+	g := New(mem, mem, Config{Backoff: Backoff{Threshold: 2, Base: time.Minute, Max: time.Hour}}, tooMany)
+	status := http.StatusUnauthorized
+	var seen string
+	h := g.Lockout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		seen = string(body)
+		w.WriteHeader(status)
+	}))
+
+	login := func(name string) int {
+		body := `{"login":"` + name + `","password":"x"}`
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if w.Code != http.StatusTooManyRequests && seen != body {
+			t.Fatalf("handler got body %q, want %q", seen, body)
+		}
+		return w.Code
+	}
+
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if got := login("Alice"); got != want {
+			t.Fatalf("attempt %d: status %d, want %d", i+1, got, want)
+		}
+	}
+	// логины различают регистр: блокировка Alice не касается alice
+	if got := login("alice"); got != http.StatusUnauthorized {
+		t.Errorf("other case login: status %d, want 401", got)
+	}
+
+	// успешный вход сбрасывает счётчик
+	status = http.StatusOK
+	if got := login("alice"); got != http.StatusOK {
+		t.Fatalf("successful login: status %d", got)
+	}
+	status = http.StatusUnauthorized
+	if got := login("alice"); got != http.StatusUnauthorized {
+		t.Errorf("after reset: status %d, want 401", got)
+	}
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// корзины и счётчики, которые не трогали дольше, удаляются
+const idleTTL = time.Hour
+
+// Memory — хранилище в памяти процесса; годится для одной реплики
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*Bucket
+	failures  map[string]*failure
+	lastSweep time.Time
+}
+
+type failure struct {
+	count       int
+	lockedUntil time.Time
+	updated     time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets:  make(map[string]*Bucket),
+		failures: make(map[string]*failure),
+	}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, rule Rule) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &Bucket{}
+		m.buckets[key] = b
+	}
+	allowed, wait := b.Take(now, rule)
+	return allowed, wait, nil
+}
+
+func (m *Memory) LockedFor(ctx context.Context, login string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f, ok := m.failures[login]; ok {
+		if d := time.Until(f.lockedUntil); d > 0 {
+			return d, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *Memory) Fail(ctx context.Context, login string, b Backoff) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	f, ok := m.failures[login]
+	if !ok {
+		f = &failure{}
+		m.failures[login] = f
+	}
+	// старые неудачи не копятся: серия начинается заново
+	if now.Sub(f.updated) > idleTTL && now.After(f.lockedUntil) {
+		f.count = 0
+	}
+	f.count++
+	f.updated = now
+
+	d := b.Duration(f.count)
+	if d > 0 {
+		f.lockedUntil = now.Add(d)
+	}
+	return d, nil
+}
+
+func (m *Memory) Reset(ctx context.Context, login string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, login)
+	return nil
+}
+
+// sweep раз в минуту выбрасывает давно не использованные записи, чтобы карта не росла бесконечно
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+
+	for k, b := range m.buckets {
+		if now.Sub(b.Updated) > idleTTL {
+			delete(m.buckets, k)
+		}
+	}
+	for k, f := range m.failures {
+		if now.Sub(f.updated) > idleTTL && now.After(f.lockedUntil) {
+			delete(m.failures, k)
+		}
+	}
+}
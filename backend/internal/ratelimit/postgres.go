@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"voicebook/internal/logging"
+)
+
+// Postgres хранит корзины и блокировки в базе, чтобы лимиты были общими для всех реплик.
+// Таблицы rate_limits и login_failures создаёт storage.InitDB.
+type Postgres struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Take(ctx context.Context, key string, rule Rule) (bool, time.Duration, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+	p.maybeSweep(ctx)
+
+	// строка блокируется до конца транзакции, поэтому реплики не берут один токен дважды
+	var b Bucket
+	var updatedMs int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT tokens, updated_ms FROM rate_limits WHERE key = $1 FOR UPDATE
+	`, key).Scan(&b.Tokens, &updatedMs)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return false, 0, err
+	default:
+		b.Updated = time.UnixMilli(updatedMs)
+	}
+
+	allowed, wait := b.Take(time.Now(), rule)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO rate_limits (key, tokens, updated_ms) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET tokens = EXCLUDED.tokens, updated_ms = EXCLUDED.updated_ms
+	`, key, b.Tokens, b.Updated.UnixMilli())
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed, wait, tx.Commit()
+}
+
+func (p *Postgres) LockedFor(ctx context.Context, login string) (time.Duration, error) {
+	var lockedUntil int64
+	err := p.db.QueryRowContext(ctx, `
+		SELECT locked_until_ms FROM login_failures WHERE login = $1
+	`, login).Scan(&lockedUntil)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return max(time.Until(time.UnixMilli(lockedUntil)), 0), nil
+}
+
+func (p *Postgres) Fail(ctx context.Context, login string, b Backoff) (time.Duration, error) {
+	now := time.Now()
+	staleBefore := now.Add(-idleTTL).UnixMilli()
+
+	// серия неудач начинается заново, если предыдущая была давно и блокировка истекла
+	var failures int
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO login_failures (login, failures, updated_ms) VALUES ($1, 1, $2)
+		ON CONFLICT (login) DO UPDATE SET
+			failures = CASE
+				WHEN login_failures.updated_ms < $3 AND login_failures.locked_until_ms < $2 THEN 1
+				ELSE login_failures.failures + 1
+			END,
+			updated_ms = $2
+		RETURNING failures
+	`, login, now.UnixMilli(), staleBefore).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	d := b.Duration(failures)
+	if d > 0 {
+		_, err = p.db.ExecContext(ctx, `
+			UPDATE login_failures SET locked_until_ms = $2 WHERE login = $1
+		`, login, now.Add(d).UnixMilli())
+	}
+	return d, err
+}
+
+func (p *Postgres) Reset(ctx context.Context, login string) error {
+	_, err := p.db.ExecContext(ctx, "DELETE FROM login_failures WHERE login = $1", login)
+	return err
+}
+
+// maybeSweep раз в минуту запускает sweep в фоне: ключи — адреса и любые присланные
+// логины, поэтому без чистки таблицы растут без предела
+func (p *Postgres) maybeSweep(ctx context.Context) {
+	now := time.Now()
+	p.mu.Lock()
+	due := now.Sub(p.lastSweep) >= time.Minute
+	if due {
+		p.lastSweep = now
+	}
+	p.mu.Unlock()
+	if !due {
+		return
+	}
+
+	logger := logging.FromContext(ctx)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	go func() {
+		defer cancel()
+		if err := p.sweep(ctx, now); err != nil {
+			logger.Warn("rate limit sweep failed", "error", err)
+		}
+	}()
+}
+
+// sweep удаляет то же, что и Memory.sweep: корзины, которые не трогали дольше idleTTL,
+// и давние серии неудач без действующей блокировки
+func (p *Postgres) sweep(ctx context.Context, now time.Time) error {
+	staleBefore := now.Add(-idleTTL).UnixMilli()
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE updated_ms < $1", staleBefore); err != nil {
+		return err
+	}
+	_, err := p.db.ExecContext(ctx,
+		"DELETE FROM login_failures WHERE updated_ms < $1 AND locked_until_ms < $2",
+		staleBefore, now.UnixMilli(),
+	)
+	return err
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Store хранит корзины токенов; ключ — область и субъект, например "login:ip:1.2.3.4"
+type Store interface {
+	// Take забирает токен; если токенов нет, возвращает false и время до появления следующего
+	Take(ctx context.Context, key string, rule Rule) (bool, time.Duration, error)
+}
+
+// Lockouts учитывает неудачные входы подряд и блокировки по ним
+type Lockouts interface {
+	// LockedFor возвращает, сколько ещё заблокирован логин (0 — не заблокирован)
+	LockedFor(ctx context.Context, login string) (time.Duration, error)
+	// Fail записывает неудачу и возвращает длительность блокировки, если она началась
+	Fail(ctx context.Context, login string, b Backoff) (time.Duration, error)
+	Reset(ctx context.Context, login string) error
+}
+
+// Rule — скорость пополнения корзины в минуту и её ёмкость
+type Rule struct {
+	PerMinute float64
+	Burst     int
+}
+
+func (r Rule) perSecond() float64 {
+	return r.PerMinute / 60
+}
+
+// Bucket — состояние корзины токенов; общий расчёт для всех хранилищ
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take пополняет корзину за прошедшее время и пытается взять токен
+func (b *Bucket) Take(now time.Time, rule Rule) (bool, time.Duration) {
+	if b.Updated.IsZero() {
+		b.Tokens = float64(rule.Burst)
+	} else if elapsed := now.Sub(b.Updated); elapsed > 0 {
+		b.Tokens = math.Min(float64(rule.Burst), b.Tokens+elapsed.Seconds()*rule.perSecond())
+	}
+	b.Updated = now
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return true, 0
+	}
+	wait := (1 - b.Tokens) / rule.perSecond()
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+// Backoff — блокировка после Threshold неудач подряд: Base, затем вдвое дольше
+// за каждую следующую неудачу, но не больше Max
+type Backoff struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
+func (b Backoff) Duration(failures int) time.Duration {
+	if b.Threshold <= 0 || failures < b.Threshold {
+		return 0
+	}
+	d := b.Base
+	for i := b.Threshold; i < failures && d < b.Max; i++ {
+		d *= 2
+	}
+	return min(d, b.Max)
+}
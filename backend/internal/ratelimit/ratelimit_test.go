@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketTake(t *testing.T) {
+	rule := Rule{PerMinute: 60, Burst: 2}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var b Bucket
+	for _, step := range []struct {
+		at      time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		// новая корзина полна
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, time.Second},
+		{500 * time.Millisecond, false, 500 * time.Millisecond},
+		{time.Second, true, 0},
+		// за минуту простоя корзина наполняется не больше ёмкости
+		{time.Minute, true, 0},
+		{time.Minute, true, 0},
+		{time.Minute, false, time.Second},
+	} {
+		allowed, wait := b.Take(start.Add(step.at), rule)
+		if allowed != step.allowed || wait != step.wait {
+			t.Errorf("at %s: Take = %v, %s; want %v, %s", step.at, allowed, wait, step.allowed, step.wait)
+		}
+	}
+}
+
+func TestBackoffDuration(t *testing.T) {
+	b := Backoff{Threshold: 3, Base: time.Minute, Max: 10 * time.Minute}
+	for failures, want := range map[int]time.Duration{
+		0:  0,
+		2:  0,
+		3:  time.Minute,
+		4:  2 * time.Minute,
+		5:  4 * time.Minute,
+		6:  8 * time.Minute,
+		7:  10 * time.Minute,
+		50: 10 * time.Minute,
+	} {
+		if got := b.Duration(failures); got != want {
+			t.Errorf("Duration(%d) = %s, want %s", failures, got, want)
+		}
+	}
+	if d := (Backoff{Base: time.Minute, Max: time.Hour}).Duration(100); d != 0 {
+		t.Errorf("Duration without threshold = %s, want 0", d)
+	}
+}
@@ -3,17 +3,22 @@ package s3client
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strings"
+	"time"
+
+	"voicebook/internal/config"
+	"voicebook/internal/logging"
+	"voicebook/internal/metrics"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/joho/godotenv"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 )
 
 type Client struct {
@@ -22,115 +27,141 @@ type Client struct {
 	endpoint string
 }
 
-func New() (*Client, error) {
-	_ = godotenv.Load(".env")
-
-	accessKey := firstNonEmpty(
-		os.Getenv("AWS_ACCESS_KEY_ID"),
-		os.Getenv("YC_S3_API_KEY"),
-	)
-	secretKey := firstNonEmpty(
-		os.Getenv("AWS_SECRET_ACCESS_KEY"),
-		os.Getenv("YC_S3_API_SECRET"),
-	)
-	bucket := firstNonEmpty(
-		os.Getenv("AWS_BUCKET"),
-		os.Getenv("S3_BUCKET"),
-		os.Getenv("YC_S3_BUCKET"),
-	)
-	region := firstNonEmpty(
-		os.Getenv("AWS_REGION"),
-		os.Getenv("AWS_DEFAULT_REGION"),
-		os.Getenv("S3_REGION"),
-		os.Getenv("YC_S3_REGION"),
-		"ru-central1",
-	)
-	endpoint := firstNonEmpty(
-		os.Getenv("AWS_ENDPOINT"),
-		os.Getenv("S3_ENDPOINT"),
-		os.Getenv("YC_S3_ENDPOINT"),
-		"https://storage.yandexcloud.net",
-	)
-
-	missing := missingVars(map[string]string{
-		"AWS_ACCESS_KEY_ID (or YC_S3_API_KEY)":          accessKey,
-		"AWS_SECRET_ACCESS_KEY (or YC_S3_API_SECRET)":   secretKey,
-		"AWS_BUCKET (or S3_BUCKET or YC_S3_BUCKET)":     bucket,
-	})
-	if len(missing) > 0 {
-		return nil, fmt.Errorf("missing required environment variables: %s", joinComma(missing))
-	}
-	creds := credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")
+// New создаёт клиент; обязательность ключей и бакета проверяет config.Validate
+func New(c config.S3) (*Client, error) {
+	creds := credentials.NewStaticCredentialsProvider(c.AccessKey, c.SecretKey, "")
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(region),
-		config.WithCredentialsProvider(creds),
-		config.WithEndpointResolver(
+	cfg, err := awsconfig.LoadDefaultConfig(context.TODO(),
+		awsconfig.WithRegion(c.Region),
+		awsconfig.WithCredentialsProvider(creds),
+		awsconfig.WithEndpointResolver(
 			aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
-				return aws.Endpoint{URL: endpoint, SigningRegion: region}, nil
+				return aws.Endpoint{URL: c.Endpoint, SigningRegion: region}, nil
 			}),
 		),
 	)
 	if err != nil {
-		fmt.Println(err)
+		return nil, fmt.Errorf("failed to load S3 config: %w", err)
 	}
 
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
 	svc := s3.NewFromConfig(cfg)
 
 	return &Client{
 		svc:      svc,
-		bucket:   bucket,
-		endpoint: endpoint,
+		bucket:   c.Bucket,
+		endpoint: c.Endpoint,
 	}, nil
 }
 
 func (c *Client) UploadFile(ctx context.Context, data []byte, filename string) (string, error) {
 	key := fmt.Sprintf("uploads/%s", filename)
 
+	start := time.Now()
 	output, err := c.svc.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: &c.bucket,
 		Key:    &key,
 		Body:   bytes.NewReader(data),
 	})
+	metrics.ObserveS3("put", start, err)
 	if err != nil {
 		return "", err
 	}
-	fmt.Println(output)
+	metrics.AddS3Bytes("upload", int64(len(data)))
 
 	url := fmt.Sprintf("%s/%s/%s", c.endpoint, c.bucket, key)
-	fmt.Println(url)
+	logging.FromContext(ctx).Debug("file uploaded", "key", key, "size", len(data), "etag", aws.ToString(output.ETag))
 
 	return url, nil
 }
 
-func firstNonEmpty(values ...string) string {
-	for _, v := range values {
-		if v != "" {
-			return v
-		}
+// PutObject кладёт данные по произвольному ключу и возвращает URL объекта
+func (c *Client) PutObject(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	size := sizeOf(body)
+	start := time.Now()
+	_, err := c.svc.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      &c.bucket,
+		Key:         &key,
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	metrics.ObserveS3("put", start, err)
+	if err != nil {
+		return "", err
 	}
-	return ""
+	metrics.AddS3Bytes("upload", size)
+	return fmt.Sprintf("%s/%s/%s", c.endpoint, c.bucket, key), nil
 }
 
-func missingVars(values map[string]string) []string {
-	var missing []string
-	for name, value := range values {
-		if value == "" {
-			missing = append(missing, name)
-		}
+// OpenFile открывает объект по URL на чтение без загрузки в память
+func (c *Client) OpenFile(ctx context.Context, url string) (io.ReadCloser, int64, error) {
+	parts := strings.Split(url, "/")
+	key := strings.Join(parts[len(parts)-2:], "/")
+
+	start := time.Now()
+	output, err := c.svc.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &c.bucket,
+		Key:    &key,
+	})
+	metrics.ObserveS3("get", start, err)
+	if err != nil {
+		return nil, 0, err
+	}
+	metrics.AddS3Bytes("download", aws.ToInt64(output.ContentLength))
+	return output.Body, aws.ToInt64(output.ContentLength), nil
+}
+
+// Exists проверяет, есть ли объект по URL
+func (c *Client) Exists(ctx context.Context, url string) (bool, error) {
+	parts := strings.Split(url, "/")
+	key := strings.Join(parts[len(parts)-2:], "/")
+
+	start := time.Now()
+	_, err := c.svc.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &c.bucket,
+		Key:    &key,
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		metrics.ObserveS3("head", start, nil)
+		return false, nil
+	}
+	metrics.ObserveS3("head", start, err)
+	if err != nil {
+		return false, err
 	}
-	return missing
+	return true, nil
+}
+
+// Ping проверяет, что бакет доступен с текущими ключами
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.svc.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &c.bucket})
+	return err
 }
 
-func joinComma(values []string) string {
-	if len(values) == 0 {
-		return ""
+// URL возвращает адрес объекта по ключу
+func (c *Client) URL(key string) string {
+	return fmt.Sprintf("%s/%s/%s", c.endpoint, c.bucket, key)
+}
+
+// sizeOf — размер тела для метрик; -1, если его не узнать без чтения
+func sizeOf(r io.Reader) int64 {
+	s, ok := r.(io.Seeker)
+	if !ok {
+		return -1
+	}
+	cur, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return -1
 	}
-	s := values[0]
-	for i := 1; i < len(values); i++ {
-		s += ", " + values[i]
+	if _, err := s.Seek(cur, io.SeekStart); err != nil {
+		return -1
 	}
-	return s
+	return end - cur
 }
 
 func (c *Client) DownloadFile(ctx context.Context, url string) ([]byte, error) {
@@ -138,44 +169,75 @@ func (c *Client) DownloadFile(ctx context.Context, url string) ([]byte, error) {
     parts := strings.Split(url, "/")
     key := strings.Join(parts[len(parts)-2:], "/") // uploads/filename
 
+    start := time.Now()
     output, err := c.svc.GetObject(ctx, &s3.GetObjectInput{
         Bucket: &c.bucket,
         Key:    &key,
     })
     if err != nil {
+        metrics.ObserveS3("get", start, err)
         return nil, err
     }
     defer output.Body.Close()
 
-    return io.ReadAll(output.Body)
+    data, err := io.ReadAll(output.Body)
+    metrics.ObserveS3("get", start, err)
+    metrics.AddS3Bytes("download", int64(len(data)))
+    return data, err
 }
 
 func (c *Client) DeleteFile(ctx context.Context, filename string) error {
 	key := fmt.Sprintf("uploads/%s", filename)
 
-	output, err := c.svc.DeleteObject(ctx, &s3.DeleteObjectInput{
+	start := time.Now()
+	_, err := c.svc.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &c.bucket,
+		Key:    &key,
+	})
+	metrics.ObserveS3("delete", start, err)
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Debug("file deleted", "key", key)
+	return nil
+}
+
+// DeleteObject удаляет объект по URL; удаление отсутствующего объекта не ошибка
+func (c *Client) DeleteObject(ctx context.Context, url string) error {
+	parts := strings.Split(url, "/")
+	key := strings.Join(parts[len(parts)-2:], "/")
+
+	start := time.Now()
+	_, err := c.svc.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: &c.bucket,
 		Key:    &key,
 	})
+	metrics.ObserveS3("delete", start, err)
 	if err != nil {
 		return err
 	}
 
-    data, _ := json.Marshal(output)
-    fmt.Println(string(data))
+	logging.FromContext(ctx).Debug("object deleted", "key", key)
 	return nil
 }
 
 func (c *Client) ShowFiles(ctx context.Context) {
 
-	result, err := c.svc.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
+	logger := logging.FromContext(ctx)
+	result, err := c.svc.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 		Bucket: aws.String(c.bucket),
 	})
 	if err != nil {
-		fmt.Println(err)
+		logger.Error("list objects failed", "error", err)
+		return
 	}
 
 	for _, object := range result.Contents {
-		fmt.Printf("object=%s size=%d Bytes last modified=%s \n", aws.ToString(object.Key), aws.ToInt64(object.Size), object.LastModified.Local().Format("2006-01-02 15:04:05 Monday"))
+		logger.Info("object",
+			"key", aws.ToString(object.Key),
+			"size", aws.ToInt64(object.Size),
+			"last_modified", aws.ToTime(object.LastModified),
+		)
 	}
 }
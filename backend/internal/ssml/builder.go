@@ -0,0 +1,191 @@
+package ssml
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"voicebook/internal/utils"
+)
+
+// паузы подобраны на слух для голосов SpeechKit
+const (
+	headingPauseMs    = 1000
+	sceneBreakPauseMs = 2000
+	paragraphPauseMs  = 500
+	ellipsisPause     = "400ms"
+	headingLeadPause  = "500ms"
+)
+
+var (
+	headingRe    = regexp.MustCompile(`(?i)^(глава|часть|книга|пролог|эпилог|chapter|part|book|prologue|epilogue)(\s+(\d+|[IVXLCDM]+)\b.*|\s+\p{L}+\s*([.:].*)?|\s*([.:].*)?)$`)
+	sceneBreakRe = regexp.MustCompile(`^[\s*#~\-—–_•]+$`)
+	ellipsisRe   = regexp.MustCompile(`\.{3}|…`)
+	dialogueRe   = regexp.MustCompile(`^[—–-]\s*`)
+)
+
+var xmlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"'", "&apos;",
+)
+
+// Segment — часть страницы, которую TTS синтезирует отдельно, чтобы знать её время:
+// заголовок или предложение. Start и End — смещения в рунах текста страницы (End
+// не включается); у разделителя сцен и у заголовка главы, которого нет в тексте
+// страницы, они совпадают.
+type Segment struct {
+	Start   int
+	End     int
+	Text    string
+	Heading bool
+	// PauseMs — тишина после сегмента: после заголовка, в конце абзаца, на месте разделителя сцен
+	PauseMs int
+}
+
+// Segments делит страницу на заголовки и предложения. Каждая строка — отдельный абзац,
+// поэтому заголовок никогда не склеивается с текстом. heading — заголовок главы,
+// которую открывает страница; он читается первым, если на самой странице заголовков нет.
+func Segments(text, heading string) []Segment {
+	var (
+		segs       []Segment
+		hasHeading bool
+		offset     int
+	)
+	for _, line := range strings.SplitAfter(text, "\n") {
+		runes := []rune(line)
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case IsSceneBreak(trimmed):
+			segs = append(segs, Segment{Start: offset, End: offset, PauseMs: sceneBreakPauseMs})
+		case IsHeading(trimmed):
+			b := utils.SentenceBounds(runes)
+			start, end := b[0][0], b[len(b)-1][1]
+			segs = append(segs, Segment{
+				Start:   offset + start,
+				End:     offset + end,
+				Text:    string(runes[start:end]),
+				Heading: true,
+				PauseMs: headingPauseMs,
+			})
+			hasHeading = true
+		default:
+			n := len(segs)
+			for _, b := range utils.SentenceBounds(runes) {
+				s := string(runes[b[0]:b[1]])
+				if !hasLetterOrDigit(s) {
+					continue
+				}
+				segs = append(segs, Segment{Start: offset + b[0], End: offset + b[1], Text: s})
+			}
+			if len(segs) > n {
+				segs[len(segs)-1].PauseMs = paragraphPauseMs
+			}
+		}
+		offset += len(runes)
+	}
+
+	heading = strings.TrimSpace(heading)
+	if heading != "" && !hasHeading {
+		segs = append([]Segment{{Text: heading, Heading: true, PauseMs: headingPauseMs}}, segs...)
+	}
+	return segs
+}
+
+// Build превращает сегмент страницы в SSML: заголовок — отдельный абзац без точки
+// в конце с паузой перед ним, реплика диалога — без тире, на многоточиях внутри
+// предложения — паузы.
+// Паузы между сегментами в разметку не входят, их добавляет TTS-сервис (PauseMs).
+// Для пустого сегмента (разделителя сцен) возвращает пустую строку.
+func Build(seg Segment) string {
+	text := strings.TrimSpace(seg.Text)
+	if text == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<speak>")
+	if seg.Heading {
+		// тега выделения в SSML SpeechKit нет: заголовок отбивается паузами с обеих сторон
+		writeBreak(&b, headingLeadPause)
+		b.WriteString("<p><s>")
+		b.WriteString(Escape(strings.TrimRight(text, ".:")))
+	} else {
+		b.WriteString("<p><s>")
+		writeSentence(&b, dialogueRe.ReplaceAllString(text, ""))
+	}
+	b.WriteString("</s></p></speak>")
+	return b.String()
+}
+
+// IsHeading сообщает, похожа ли строка на заголовок главы
+func IsHeading(line string) bool {
+	if utf8.RuneCountInString(line) > 60 {
+		return false
+	}
+	if headingRe.MatchString(line) {
+		return true
+	}
+	// короткая строка целиком заглавными, например "ВОЙНА И МИР". Реплика ("— СТОЙ!"),
+	// восклицание или вопрос — это крик, а строка из одних аббревиатур ("США И СССР") —
+	// не заголовок: нужно хотя бы одно слово длиннее четырёх букв
+	end := strings.TrimRight(line, `»"”')`)
+	if dialogueRe.MatchString(line) || strings.HasSuffix(end, "!") || strings.HasSuffix(end, "?") ||
+		strings.HasSuffix(end, "…") || strings.HasSuffix(end, "...") {
+		return false
+	}
+	word, longest := 0, 0
+	for _, r := range line {
+		if !unicode.IsLetter(r) {
+			word = 0
+			continue
+		}
+		if !unicode.IsUpper(r) {
+			return false
+		}
+		word++
+		longest = max(longest, word)
+	}
+	return longest >= 5
+}
+
+// IsSceneBreak сообщает, является ли строка разделителем сцен ("***", "* * *", "---")
+func IsSceneBreak(line string) bool {
+	return sceneBreakRe.MatchString(line)
+}
+
+// Escape экранирует спецсимволы XML
+func Escape(s string) string {
+	return xmlEscaper.Replace(s)
+}
+
+// writeSentence экранирует предложение и добавляет паузы на многоточиях внутри него
+func writeSentence(b *strings.Builder, s string) {
+	parts := ellipsisRe.Split(s, -1)
+	for i, part := range parts {
+		b.WriteString(Escape(part))
+		if i < len(parts)-1 {
+			b.WriteString("…")
+			writeBreak(b, ellipsisPause)
+		}
+	}
+}
+
+func writeBreak(b *strings.Builder, d string) {
+	b.WriteString(`<break time="`)
+	b.WriteString(d)
+	b.WriteString(`"/>`)
+}
+
+func hasLetterOrDigit(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
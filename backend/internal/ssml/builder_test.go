@@ -0,0 +1,29 @@
+package ssml
+
+import "testing"
+
+func TestIsHeading(t *testing.T) {
+	for line, want := range map[string]bool{
+		"Глава 1":               true,
+		"ГЛАВА ПЕРВАЯ":          true,
+		"Часть II. Возвращение": true,
+		"Chapter XII":           true,
+		"Пролог":                true,
+		"ВОЙНА И МИР":           true,
+		"ВОСКРЕСЕНИЕ.":          true,
+		"— СТОЙ!":               false,
+		"— ПОДОЖДИТЕ":           false,
+		"ПОМОГИТЕ!":             false,
+		"КТО ТАМ ХОДИТ?":        false,
+		"«НИКОГДА!»":            false,
+		"И ТОГДА...":            false,
+		"США И СССР":            false,
+		"ВОЙНА и мир":           false,
+		"Он пришёл домой.":      false,
+		"":                      false,
+	} {
+		if got := IsHeading(line); got != want {
+			t.Errorf("IsHeading(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
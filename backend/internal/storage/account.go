@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Назначения одноразовых токенов из писем
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+// SaveUserToken сохраняет хеш токена; прежние неиспользованные токены того же
+// назначения отзываются, чтобы действовала только последняя ссылка
+func (s *Storage) SaveUserToken(ctx context.Context, login, purpose, tokenHash, email string, expires time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE user_tokens SET used = TRUE WHERE login = $1 AND purpose = $2 AND NOT used",
+		login, purpose,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO user_tokens (token_hash, login, purpose, email, expires_ts)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+	`, tokenHash, login, purpose, email, expires.Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseUserToken гасит токен и возвращает владельца и адрес, на который ушло письмо
+func (s *Storage) UseUserToken(ctx context.Context, purpose, tokenHash string) (login, email string, err error) {
+	var nullEmail sql.NullString
+	err = s.db.QueryRowContext(ctx, `
+		UPDATE user_tokens SET used = TRUE
+		WHERE token_hash = $1 AND purpose = $2 AND NOT used AND expires_ts > $3
+		RETURNING login, email
+	`, tokenHash, purpose, time.Now().Unix()).Scan(&login, &nullEmail)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrUserTokenInvalid
+	}
+	return login, nullEmail.String, err
+}
+
+// GetUserEmail возвращает подтверждённый email или "", если его нет
+func (s *Storage) GetUserEmail(ctx context.Context, login string) (string, error) {
+	var email sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT email FROM users WHERE login = $1", login).Scan(&email)
+	if err != nil {
+		return "", notFound(err, ErrUserNotFound)
+	}
+	return email.String, nil
+}
+
+// SetUserEmail записывает подтверждённый email
+func (s *Storage) SetUserEmail(ctx context.Context, login, email string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET email = $2 WHERE login = $1", login, email)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrEmailExists
+	}
+	return err
+}
+
+// SetPassword меняет пароль и закрывает все сессии и refresh-токены пользователя
+func (s *Storage) SetPassword(ctx context.Context, login, password string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE users SET password = $2 WHERE login = $1", login, password)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrUserNotFound
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM sessions WHERE login = $1", login); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked = TRUE WHERE login = $1", login); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Роли пользователей
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// UserInfo — пользователь с занятым местом: исходники книг и собранные экспорты
+type UserInfo struct {
+	Login       string `json:"login"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	Disabled    bool   `json:"disabled"`
+	Books       int64  `json:"books"`
+	StoredBytes int64  `json:"storedBytes"`
+}
+
+const userInfoQuery = `
+	SELECT u.login, COALESCE(u.email, ''), u.role, u.disabled,
+		(SELECT count(*) FROM books b WHERE b.login = u.login),
+		(SELECT COALESCE(sum(b.size_bytes), 0) FROM books b WHERE b.login = u.login) +
+		(SELECT COALESCE(sum(e.size_bytes), 0) FROM export_jobs e WHERE e.login = u.login)
+	FROM users u
+`
+
+func scanUserInfo(row scanner) (UserInfo, error) {
+	var u UserInfo
+	err := row.Scan(&u.Login, &u.Email, &u.Role, &u.Disabled, &u.Books, &u.StoredBytes)
+	return u, err
+}
+
+func (s *Storage) GetUserInfo(ctx context.Context, login string) (UserInfo, error) {
+	u, err := scanUserInfo(s.db.QueryRowContext(ctx, userInfoQuery+"WHERE u.login = $1", login))
+	return u, notFound(err, ErrUserNotFound)
+}
+
+func (s *Storage) ListUsers(ctx context.Context, limit, offset int) ([]UserInfo, error) {
+	rows, err := s.db.QueryContext(ctx, userInfoQuery+"ORDER BY u.id LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserInfo{}
+	for rows.Next() {
+		u, err := scanUserInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetUserAccess возвращает роль пользователя и признак отключённого аккаунта
+func (s *Storage) GetUserAccess(ctx context.Context, login string) (role string, disabled bool, err error) {
+	err = s.db.QueryRowContext(ctx, "SELECT role, disabled FROM users WHERE login = $1", login).Scan(&role, &disabled)
+	return role, disabled, notFound(err, ErrUserNotFound)
+}
+
+// UserActive — аккаунт существует, не отключён и не удаляется
+func (s *Storage) UserActive(ctx context.Context, login string) (bool, error) {
+	var active bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT NOT u.disabled AND NOT EXISTS (SELECT 1 FROM account_deletions d WHERE d.login = u.login)
+		  FROM users u
+		 WHERE u.login = $1`, login,
+	).Scan(&active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return active, err
+}
+
+func (s *Storage) SetUserRole(ctx context.Context, login, role string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET role = $2 WHERE login = $1", login, role)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// SetUserDisabled отключает или включает аккаунт. Отключение закрывает все сессии
+// и refresh-токены; фиды отключённого пользователя не отдаются, пока его не включат.
+func (s *Storage) SetUserDisabled(ctx context.Context, login string, disabled bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE users SET disabled = $2 WHERE login = $1", login, disabled)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrUserNotFound
+	}
+	if disabled {
+		if err := revokeUserSessions(ctx, tx, login); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RevokeUserSessions закрывает все сессии и refresh-токены пользователя
+func (s *Storage) RevokeUserSessions(ctx context.Context, login string) error {
+	return revokeUserSessions(ctx, s.db, login)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func revokeUserSessions(ctx context.Context, db execer, login string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE login = $1", login); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked = TRUE WHERE login = $1", login)
+	return err
+}
+
+// PromoteAdmins выдаёт роль admin перечисленным логинам; так назначается первый администратор
+func (s *Storage) PromoteAdmins(ctx context.Context, logins []string) error {
+	if len(logins) == 0 {
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET role = 'admin' WHERE login = ANY($1)", pq.Array(logins))
+	return err
+}
+
+// ForceDeleteBook удаляет книгу любого пользователя вместе со страницами, настройками,
+// прогрессом и экспортами. Возвращает URL объектов S3, которые после этого ни на что
+// не нужны: исходник (если его не загрузил кто-то ещё) и файлы экспортов. Удаляет их вызывающий.
+// Книга каталога так же пропадает из коллекций всех читателей.
+func (s *Storage) ForceDeleteBook(ctx context.Context, bookID int64) (Book, []string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Book{}, nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		b       Book
+		bookURL sql.NullString
+		author  sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM books WHERE bookId = $1
+		RETURNING bookId, login, uploadedTs, bookUrl, title, author
+	`, bookID).Scan(&b.BookID, &b.Login, &b.UploadedTs, &bookURL, &b.Title, &author)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Book{}, nil, ErrBookNotFound
+	}
+	if err != nil {
+		return Book{}, nil, err
+	}
+	b.BookUrl, b.Author = bookURL.String, author.String
+
+	for _, q := range []string{
+		"DELETE FROM book_pages WHERE book_id = $1",
+		"DELETE FROM book_settings WHERE book_id = $1",
+		"DELETE FROM user_progress WHERE book_id = $1",
+		"DELETE FROM book_shares WHERE book_id = $1",
+		"DELETE FROM library_refs WHERE book_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, q, bookID); err != nil {
+			return Book{}, nil, err
+		}
+	}
+
+	var urls []string
+	rows, err := tx.QueryContext(ctx, "DELETE FROM export_jobs WHERE book_id = $1 RETURNING file_url", bookID)
+	if err != nil {
+		return Book{}, nil, err
+	}
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			rows.Close()
+			return Book{}, nil, err
+		}
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Book{}, nil, err
+	}
+
+	if b.BookUrl != "" {
+		var shared bool
+		if err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM books WHERE bookUrl = $1)", b.BookUrl,
+		).Scan(&shared); err != nil {
+			return Book{}, nil, err
+		}
+		if !shared {
+			urls = append(urls, b.BookUrl)
+		}
+	}
+	return b, urls, tx.Commit()
+}
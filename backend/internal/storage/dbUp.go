@@ -43,7 +43,172 @@ func (s *Storage) InitDB() error {
 		page_id BIGINT NOT NULL,
 		PRIMARY KEY (login, book_id)
 	);`
+	createUserSettings := `
+	CREATE TABLE IF NOT EXISTS user_settings (
+		login TEXT PRIMARY KEY,
+		voice TEXT NOT NULL,
+		role TEXT NOT NULL,
+		speed DOUBLE PRECISION NOT NULL,
+		pitch_shift INT NOT NULL,
+		format TEXT NOT NULL
+	);`
+	createBookSettings := `
+	CREATE TABLE IF NOT EXISTS book_settings (
+		login TEXT NOT NULL,
+		book_id BIGINT NOT NULL,
+		voice TEXT,
+		role TEXT,
+		speed DOUBLE PRECISION,
+		pitch_shift INT,
+		format TEXT,
+		PRIMARY KEY (login, book_id)
+	);`
 
+	// format — значение из transcode; раньше Ogg Opus записывался как "opus".
+	// Одну и ту же книгу (главу) в одном формате одновременно собирает не больше одной задачи:
+	// повторный запрос получает уже поставленную. Дубли, оставшиеся с прежних версий, снимаются.
+	createExportJobs := `
+	CREATE TABLE IF NOT EXISTS export_jobs (
+		id BIGSERIAL PRIMARY KEY,
+		login TEXT NOT NULL,
+		book_id BIGINT NOT NULL,
+		chapter INT,
+		format TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		error TEXT NOT NULL DEFAULT '',
+		file_url TEXT NOT NULL DEFAULT '',
+		created_ts BIGINT NOT NULL DEFAULT (extract(epoch from now())::BIGINT),
+		updated_ts BIGINT NOT NULL DEFAULT (extract(epoch from now())::BIGINT)
+	);
+	ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS duration_ms BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS size_bytes BIGINT NOT NULL DEFAULT 0;
+	UPDATE export_jobs SET format = 'oggopus' WHERE format = 'opus';
+	UPDATE export_jobs j SET status = 'failed', error = 'duplicate export'
+	WHERE status IN ('pending', 'running') AND EXISTS (
+		SELECT 1 FROM export_jobs o
+		WHERE o.login = j.login AND o.book_id = j.book_id AND o.format = j.format
+		  AND COALESCE(o.chapter, 0) = COALESCE(j.chapter, 0)
+		  AND o.status IN ('pending', 'running') AND o.id < j.id
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS export_jobs_unfinished_idx
+		ON export_jobs (login, book_id, (COALESCE(chapter, 0)), format)
+		WHERE status IN ('pending', 'running');`
+	createFeedTokens := `
+	CREATE TABLE IF NOT EXISTS feed_tokens (
+		token_hash TEXT PRIMARY KEY,
+		login TEXT NOT NULL,
+		created_ts BIGINT NOT NULL DEFAULT (extract(epoch from now())::BIGINT),
+		revoked BOOLEAN NOT NULL DEFAULT FALSE
+	);`
+	// refresh-токены хранятся как хеши; использованный токен отзывается и заменяется новым
+	createRefreshTokens := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		token_hash TEXT PRIMARY KEY,
+		login TEXT NOT NULL,
+		created_ts BIGINT NOT NULL DEFAULT (extract(epoch from now())::BIGINT),
+		expires_ts BIGINT NOT NULL,
+		revoked BOOLEAN NOT NULL DEFAULT FALSE
+	);
+	CREATE INDEX IF NOT EXISTS refresh_tokens_login_idx ON refresh_tokens (login);`
+	// вход через OIDC: привязка внешних учётных записей к users и незавершённые входы.
+	// email у users заполняется только подтверждённым адресом
+	createIdentities := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT;
+	CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (lower(email));
+	CREATE TABLE IF NOT EXISTS user_identities (
+		issuer TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		login TEXT NOT NULL,
+		email TEXT,
+		created_ts BIGINT NOT NULL DEFAULT (extract(epoch from now())::BIGINT),
+		PRIMARY KEY (issuer, subject)
+	);
+	CREATE TABLE IF NOT EXISTS oidc_states (
+		state TEXT PRIMARY KEY,
+		nonce TEXT NOT NULL,
+		verifier TEXT NOT NULL,
+		expires_ts BIGINT NOT NULL
+	);`
+	// одноразовые токены из писем: подтверждение email и сброс пароля
+	createUserTokens := `
+	CREATE TABLE IF NOT EXISTS user_tokens (
+		token_hash TEXT PRIMARY KEY,
+		login TEXT NOT NULL,
+		purpose TEXT NOT NULL,
+		email TEXT,
+		created_ts BIGINT NOT NULL DEFAULT (extract(epoch from now())::BIGINT),
+		expires_ts BIGINT NOT NULL,
+		used BOOLEAN NOT NULL DEFAULT FALSE
+	);
+	CREATE INDEX IF NOT EXISTS user_tokens_login_idx ON user_tokens (login, purpose);`
+	// состояние ratelimit.Postgres
+	createRateLimits := `
+	CREATE TABLE IF NOT EXISTS rate_limits (
+		key TEXT PRIMARY KEY,
+		tokens DOUBLE PRECISION NOT NULL,
+		updated_ms BIGINT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS login_failures (
+		login TEXT PRIMARY KEY,
+		failures INT NOT NULL DEFAULT 0,
+		locked_until_ms BIGINT NOT NULL DEFAULT 0,
+		updated_ms BIGINT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS rate_limits_updated_idx ON rate_limits (updated_ms);
+	CREATE INDEX IF NOT EXISTS login_failures_updated_idx ON login_failures (updated_ms);`
+	// удаления аккаунтов, которые ещё не дошли до конца
+	createAccountDeletions := `
+	CREATE TABLE IF NOT EXISTS account_deletions (
+		login TEXT PRIMARY KEY,
+		status TEXT NOT NULL DEFAULT 'pending',
+		error TEXT NOT NULL DEFAULT '',
+		created_ts BIGINT NOT NULL DEFAULT (extract(epoch from now())::BIGINT),
+		updated_ts BIGINT NOT NULL DEFAULT (extract(epoch from now())::BIGINT)
+	);`
+	// роли, отключённые аккаунты и размер исходника книги для учёта места
+	createRoles := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE books ADD COLUMN IF NOT EXISTS size_bytes BIGINT NOT NULL DEFAULT 0;`
+	// переопределения лимитов и учёт синтезированных символов по месяцам
+	createQuotas := `
+	CREATE TABLE IF NOT EXISTS user_quotas (
+		login TEXT PRIMARY KEY,
+		max_bytes BIGINT,
+		max_books BIGINT,
+		monthly_chars BIGINT
+	);
+	CREATE TABLE IF NOT EXISTS tts_usage (
+		login TEXT NOT NULL,
+		month TEXT NOT NULL,
+		chars BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (login, month)
+	);`
+	// доступ на чтение к чужим книгам: по логину или по ссылке-приглашению (хеш токена)
+	createBookShares := `
+	CREATE TABLE IF NOT EXISTS book_shares (
+		id BIGSERIAL PRIMARY KEY,
+		book_id BIGINT NOT NULL,
+		login TEXT,
+		token_hash TEXT UNIQUE,
+		expires_ts BIGINT,
+		created_ts BIGINT NOT NULL DEFAULT (extract(epoch from now())::BIGINT),
+		CHECK ((login IS NULL) <> (token_hash IS NULL))
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS book_shares_login_idx ON book_shares (book_id, login) WHERE login IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS book_shares_recipient_idx ON book_shares (login) WHERE login IS NOT NULL;`
+	// публичный каталог: книги владельца '@library' и ссылки на них в коллекциях.
+	// По хешу текста повторная загрузка книги из каталога становится ссылкой
+	createLibrary := `
+	ALTER TABLE books ADD COLUMN IF NOT EXISTS content_hash TEXT;
+	CREATE INDEX IF NOT EXISTS books_library_hash_idx ON books (content_hash) WHERE login = '@library';
+	CREATE TABLE IF NOT EXISTS library_refs (
+		login TEXT NOT NULL,
+		book_id BIGINT NOT NULL,
+		added_ts BIGINT NOT NULL DEFAULT (extract(epoch from now())::BIGINT),
+		PRIMARY KEY (login, book_id)
+	);
+	CREATE INDEX IF NOT EXISTS library_refs_book_idx ON library_refs (book_id);`
 	if _, err := s.db.Exec(createUsers); err != nil {
 		return err
 	}
@@ -64,5 +229,57 @@ func (s *Storage) InitDB() error {
 		return err
 	}
 
+	if _, err := s.db.Exec(createUserSettings); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createBookSettings); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createExportJobs); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createFeedTokens); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createRefreshTokens); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createIdentities); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createUserTokens); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createRateLimits); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createAccountDeletions); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createRoles); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createQuotas); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createBookShares); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(createLibrary); err != nil {
+		return err
+	}
+
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,169 @@
+package storage
+
+import "context"
+
+const (
+	DeletionPending = "pending"
+	DeletionRunning = "running"
+	DeletionFailed  = "failed"
+)
+
+// AccountDeletion — удаление аккаунта в фоне. Строка живёт, пока данные не удалены:
+// последним шагом она удаляется вместе с пользователем.
+type AccountDeletion struct {
+	Login     string `json:"login"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	CreatedTs int64  `json:"createdTs"`
+	UpdatedTs int64  `json:"updatedTs"`
+}
+
+const deletionColumns = `login, status, error, created_ts, updated_ts`
+
+func scanAccountDeletion(row scanner) (AccountDeletion, error) {
+	var d AccountDeletion
+	err := row.Scan(&d.Login, &d.Status, &d.Error, &d.CreatedTs, &d.UpdatedTs)
+	return d, err
+}
+
+// StartAccountDeletion заводит задачу удаления и сразу отрезает пользователю доступ:
+// сессии, refresh-токены, токены фидов и письма перестают действовать, а пароль,
+// email и привязки к провайдерам стираются, так что войти заново уже нельзя.
+// Повторный вызов возвращает уже заведённую задачу.
+func (s *Storage) StartAccountDeletion(ctx context.Context, login string) (AccountDeletion, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return AccountDeletion{}, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE users SET password = '', email = NULL WHERE login = $1", login)
+	if err != nil {
+		return AccountDeletion{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return AccountDeletion{}, err
+	} else if n == 0 {
+		return AccountDeletion{}, ErrUserNotFound
+	}
+
+	for _, q := range []string{
+		"DELETE FROM sessions WHERE login = $1",
+		"DELETE FROM user_identities WHERE login = $1",
+		"DELETE FROM user_tokens WHERE login = $1",
+		"UPDATE refresh_tokens SET revoked = TRUE WHERE login = $1",
+		"UPDATE feed_tokens SET revoked = TRUE WHERE login = $1",
+		"INSERT INTO account_deletions (login) VALUES ($1) ON CONFLICT (login) DO NOTHING",
+	} {
+		if _, err := tx.ExecContext(ctx, q, login); err != nil {
+			return AccountDeletion{}, err
+		}
+	}
+
+	d, err := scanAccountDeletion(tx.QueryRowContext(ctx,
+		"SELECT "+deletionColumns+" FROM account_deletions WHERE login = $1", login,
+	))
+	if err != nil {
+		return AccountDeletion{}, err
+	}
+	return d, tx.Commit()
+}
+
+func (s *Storage) UpdateAccountDeletion(ctx context.Context, login, status, errMsg string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE account_deletions
+		SET status = $2, error = $3, updated_ts = extract(epoch from now())::BIGINT
+		WHERE login = $1
+	`, login, status, errMsg)
+	return err
+}
+
+// GetUnfinishedAccountDeletions — все незавершённые удаления, включая упавшие:
+// аккаунт уже недоступен, поэтому удаление должно дойти до конца
+func (s *Storage) GetUnfinishedAccountDeletions(ctx context.Context) ([]AccountDeletion, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+deletionColumns+`
+		FROM account_deletions
+		ORDER BY created_ts ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []AccountDeletion
+	for rows.Next() {
+		d, err := scanAccountDeletion(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+// GetUserObjectURLs возвращает объекты S3, принадлежащие только этому пользователю:
+// исходники книг (если тот же файл не загружен кем-то ещё) и собранные экспорты,
+// в том числе экспорты его книг, собранные читателями, с которыми он ими поделился.
+// Аудио страниц лежит в общем кеше TTS по хешу текста и сюда не входит.
+func (s *Storage) GetUserObjectURLs(ctx context.Context, login string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT b.bookUrl FROM books b
+		WHERE b.login = $1 AND b.bookUrl IS NOT NULL AND b.bookUrl <> ''
+		  AND NOT EXISTS (SELECT 1 FROM books o WHERE o.bookUrl = b.bookUrl AND o.login <> $1)
+		UNION
+		SELECT file_url FROM export_jobs
+		WHERE (login = $1 OR book_id IN (SELECT bookId FROM books WHERE login = $1)) AND file_url <> ''
+	`, login)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
+
+// DeleteUserData удаляет все строки пользователя одной транзакцией, в том числе
+// саму задачу удаления
+func (s *Storage) DeleteUserData(ctx context.Context, login string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, q := range []string{
+		// строки других читателей открытых ими книг уходят вместе с книгами
+		"DELETE FROM book_pages WHERE book_id IN (SELECT bookId FROM books WHERE login = $1)",
+		"DELETE FROM book_shares WHERE book_id IN (SELECT bookId FROM books WHERE login = $1) OR login = $1",
+		"DELETE FROM user_progress WHERE book_id IN (SELECT bookId FROM books WHERE login = $1) OR login = $1",
+		"DELETE FROM book_settings WHERE book_id IN (SELECT bookId FROM books WHERE login = $1) OR login = $1",
+		"DELETE FROM export_jobs WHERE book_id IN (SELECT bookId FROM books WHERE login = $1) OR login = $1",
+		"DELETE FROM books WHERE login = $1",
+		"DELETE FROM user_settings WHERE login = $1",
+		"DELETE FROM library_refs WHERE login = $1",
+		"DELETE FROM feed_tokens WHERE login = $1",
+		"DELETE FROM refresh_tokens WHERE login = $1",
+		"DELETE FROM user_tokens WHERE login = $1",
+		"DELETE FROM user_identities WHERE login = $1",
+		"DELETE FROM sessions WHERE login = $1",
+		"DELETE FROM login_failures WHERE login = $1",
+		"DELETE FROM user_quotas WHERE login = $1",
+		"DELETE FROM tts_usage WHERE login = $1",
+		"DELETE FROM users WHERE login = $1",
+		"DELETE FROM account_deletions WHERE login = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, q, login); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Доменные ошибки хранилища; обработчики переводят их в HTTP-статусы через errors.Is
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrConflict  = errors.New("already exists")
+	ErrForbidden = errors.New("access denied")
+)
+
+var (
+	ErrUserExists       = fmt.Errorf("user %w", ErrConflict)
+	ErrEmailExists      = fmt.Errorf("email %w", ErrConflict)
+	ErrUserDisabled     = fmt.Errorf("account is disabled, %w", ErrForbidden)
+	ErrUserNotFound     = fmt.Errorf("user %w", ErrNotFound)
+	ErrBookNotFound     = fmt.Errorf("book %w", ErrNotFound)
+	ErrPageNotFound     = fmt.Errorf("page %w", ErrNotFound)
+	ErrProgressNotFound = fmt.Errorf("progress %w", ErrNotFound)
+	ErrExportNotFound   = fmt.Errorf("export %w", ErrNotFound)
+	ErrIdentityNotFound = fmt.Errorf("identity %w", ErrNotFound)
+	ErrShareNotFound    = fmt.Errorf("share %w", ErrNotFound)
+	// экспорт ещё собирается, удалить его можно после завершения
+	ErrExportRunning = fmt.Errorf("export is in progress, %w", ErrConflict)
+	// месячный лимит синтеза не вмещает запрошенные символы
+	ErrTTSQuotaExceeded = errors.New("monthly speech synthesis quota exceeded")
+	// удалять книгу и управлять доступом к ней может только владелец
+	ErrNotBookOwner = fmt.Errorf("only the owner can do this, %w", ErrForbidden)
+	// книга уже в каталоге или там есть книга с тем же текстом
+	ErrAlreadyPublished = fmt.Errorf("book in the library %w", ErrConflict)
+	// ссылка-приглашение неизвестна, истекла или отозвана
+	ErrInviteInvalid = errors.New("invite is invalid or expired")
+	// вход через OIDC не начинался здесь или устарел
+	ErrOIDCStateNotFound = fmt.Errorf("login state %w", ErrNotFound)
+	// токен из письма неизвестен, истёк или уже использован
+	ErrUserTokenInvalid = errors.New("token is invalid or expired")
+	// неизвестный, истёкший или уже использованный refresh-токен
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+)
+
+// notFound подменяет sql.ErrNoRows доменной ошибкой, остальные ошибки отдаёт как есть
+func notFound(err, domain error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+type ExportJob struct {
+	ID         int64  `json:"exportId"`
+	Login      string `json:"-"`
+	BookID     int64  `json:"bookId"`
+	Chapter    *int   `json:"chapter"`
+	Format     string `json:"format"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	FileURL    string `json:"-"`
+	DurationMs int64  `json:"durationMs"`
+	SizeBytes  int64  `json:"sizeBytes"`
+	CreatedTs  int64  `json:"createdTs"`
+	UpdatedTs  int64  `json:"updatedTs"`
+}
+
+const exportColumns = `id, login, book_id, chapter, format, status, error, file_url, duration_ms, size_bytes, created_ts, updated_ts`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExportJob(row scanner) (ExportJob, error) {
+	var j ExportJob
+	err := row.Scan(&j.ID, &j.Login, &j.BookID, &j.Chapter, &j.Format, &j.Status, &j.Error, &j.FileURL, &j.DurationMs, &j.SizeBytes, &j.CreatedTs, &j.UpdatedTs)
+	return j, err
+}
+
+// CreateExportJob ставит книгу (главу) в очередь экспорта. Если такая же задача уже ждёт
+// или собирается, возвращает её и created = false: ставить её в очередь второй раз не нужно.
+func (s *Storage) CreateExportJob(ctx context.Context, login string, bookID int64, chapter *int, format string) (job ExportJob, created bool, err error) {
+	// между вставкой и поиском прежняя задача могла завершиться — тогда вставка пройдёт со второй попытки
+	for attempt := 0; attempt < 3; attempt++ {
+		job, err = scanExportJob(s.db.QueryRowContext(ctx, `
+			INSERT INTO export_jobs (login, book_id, chapter, format)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (login, book_id, (COALESCE(chapter, 0)), format)
+				WHERE status IN ('pending', 'running')
+			DO NOTHING
+			RETURNING `+exportColumns,
+			login, bookID, chapter, format,
+		))
+		if !errors.Is(err, sql.ErrNoRows) {
+			return job, err == nil, err
+		}
+
+		job, err = scanExportJob(s.db.QueryRowContext(ctx, `
+			SELECT `+exportColumns+`
+			FROM export_jobs
+			WHERE login = $1 AND book_id = $2 AND COALESCE(chapter, 0) = COALESCE($3, 0)
+			  AND format = $4 AND status IN ('pending', 'running')
+		`, login, bookID, chapter, format))
+		if !errors.Is(err, sql.ErrNoRows) {
+			return job, false, err
+		}
+	}
+	return ExportJob{}, false, err
+}
+
+func (s *Storage) GetExportJob(ctx context.Context, id int64, login string) (ExportJob, error) {
+	j, err := scanExportJob(s.db.QueryRowContext(ctx, `
+		SELECT `+exportColumns+`
+		FROM export_jobs
+		WHERE id = $1 AND login = $2
+	`, id, login))
+	return j, notFound(err, ErrExportNotFound)
+}
+
+func (s *Storage) UpdateExportJob(ctx context.Context, id int64, status, errMsg, fileURL string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE export_jobs
+		SET status = $2, error = $3, file_url = $4,
+			updated_ts = extract(epoch from now())::BIGINT
+		WHERE id = $1
+	`, id, status, errMsg, fileURL)
+	return err
+}
+
+func (s *Storage) FinishExportJob(ctx context.Context, id int64, fileURL string, durationMs, sizeBytes int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE export_jobs
+		SET status = 'done', error = '', file_url = $2, duration_ms = $3, size_bytes = $4,
+			updated_ts = extract(epoch from now())::BIGINT
+		WHERE id = $1
+	`, id, fileURL, durationMs, sizeBytes)
+	return err
+}
+
+// DeleteExportJob удаляет завершённую задачу экспорта и возвращает её; файл в S3
+// удаляет вызывающий. Задачу, которая ещё собирается, удалить нельзя: сборка
+// загрузила бы файл уже после удаления строки.
+func (s *Storage) DeleteExportJob(ctx context.Context, id int64, login string) (ExportJob, error) {
+	j, err := scanExportJob(s.db.QueryRowContext(ctx, `
+		DELETE FROM export_jobs
+		WHERE id = $1 AND login = $2 AND status IN ('done', 'failed')
+		RETURNING `+exportColumns,
+		id, login,
+	))
+	if !errors.Is(err, sql.ErrNoRows) {
+		return j, err
+	}
+	if _, err := s.GetExportJob(ctx, id, login); err != nil {
+		return ExportJob{}, err
+	}
+	return ExportJob{}, ErrExportRunning
+}
+
+// GetChapterExports возвращает для каждой главы книги последнюю не упавшую задачу
+// экспорта, а если такой нет — последнюю упавшую, чтобы было видно ошибку
+func (s *Storage) GetChapterExports(ctx context.Context, login string, bookID int64, format string) (map[int]ExportJob, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (chapter) `+exportColumns+`
+		FROM export_jobs
+		WHERE login = $1 AND book_id = $2 AND format = $3 AND chapter IS NOT NULL
+		ORDER BY chapter, status <> 'failed' DESC, id DESC
+	`, login, bookID, format)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make(map[int]ExportJob)
+	for rows.Next() {
+		j, err := scanExportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs[*j.Chapter] = j
+	}
+	return jobs, rows.Err()
+}
+
+// GetUnfinishedExportJobs — задачи, прерванные остановкой сервера
+func (s *Storage) GetUnfinishedExportJobs(ctx context.Context) ([]ExportJob, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+exportColumns+`
+		FROM export_jobs
+		WHERE status IN ('pending', 'running')
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []ExportJob
+	for rows.Next() {
+		j, err := scanExportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ReplaceFeedToken отзывает прежние токены пользователя и сохраняет новый
+func (s *Storage) ReplaceFeedToken(ctx context.Context, login, tokenHash string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE feed_tokens SET revoked = TRUE WHERE login = $1", login); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO feed_tokens (token_hash, login) VALUES ($1, $2)", tokenHash, login); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) RevokeFeedTokens(ctx context.Context, login string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE feed_tokens SET revoked = TRUE WHERE login = $1", login)
+	return err
+}
+
+// GetLoginByFeedToken возвращает "" для неизвестного или отозванного токена и отключённого пользователя
+func (s *Storage) GetLoginByFeedToken(ctx context.Context, tokenHash string) (string, error) {
+	var login string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT f.login FROM feed_tokens f JOIN users u ON u.login = f.login
+		 WHERE f.token_hash = $1 AND NOT f.revoked AND NOT u.disabled`,
+		tokenHash,
+	).Scan(&login)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return login, err
+}
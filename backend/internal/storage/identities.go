@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SaveOIDCState запоминает начатый вход; заодно удаляет брошенные входы
+func (s *Storage) SaveOIDCState(ctx context.Context, state, nonce, verifier string, expires time.Time) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM oidc_states WHERE expires_ts <= $1", time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO oidc_states (state, nonce, verifier, expires_ts) VALUES ($1, $2, $3, $4)",
+		state, nonce, verifier, expires.Unix(),
+	)
+	return err
+}
+
+// TakeOIDCState возвращает nonce и verifier входа и удаляет его: state одноразовый
+func (s *Storage) TakeOIDCState(ctx context.Context, state string) (nonce, verifier string, err error) {
+	err = s.db.QueryRowContext(ctx, `
+		DELETE FROM oidc_states WHERE state = $1 AND expires_ts > $2
+		RETURNING nonce, verifier
+	`, state, time.Now().Unix()).Scan(&nonce, &verifier)
+	return nonce, verifier, notFound(err, ErrOIDCStateNotFound)
+}
+
+func (s *Storage) GetLoginByIdentity(ctx context.Context, issuer, subject string) (string, error) {
+	var login string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT login FROM user_identities WHERE issuer = $1 AND subject = $2",
+		issuer, subject,
+	).Scan(&login)
+	return login, notFound(err, ErrIdentityNotFound)
+}
+
+// GetLoginByEmail ищет пользователя по подтверждённому email без учёта регистра
+func (s *Storage) GetLoginByEmail(ctx context.Context, email string) (string, error) {
+	var login string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT login FROM users WHERE lower(email) = lower($1)",
+		email,
+	).Scan(&login)
+	return login, notFound(err, ErrUserNotFound)
+}
+
+func (s *Storage) LinkIdentity(ctx context.Context, issuer, subject, login, email string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_identities (issuer, subject, login, email) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING
+	`, issuer, subject, login, email)
+	return err
+}
+
+// CreateUserWithIdentity заводит пользователя при первом входе через провайдера.
+// email сохраняется, только если он подтверждён (иначе передаётся пустым).
+func (s *Storage) CreateUserWithIdentity(ctx context.Context, login, password, email, issuer, subject string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO users (login, password, email) VALUES ($1, $2, NULLIF($3, ''))",
+		login, password, email,
+	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		if pqErr.Constraint == "users_email_idx" {
+			return ErrEmailExists
+		}
+		return ErrUserExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert user: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO user_identities (issuer, subject, login, email) VALUES ($1, $2, $3, $4)",
+		issuer, subject, login, email,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
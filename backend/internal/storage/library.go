@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+)
+
+// LibraryOwner — владелец книг публичного каталога. Логин пользователя начинается
+// с буквы или цифры, поэтому с ним не совпадёт. Книга каталога хранится один раз:
+// страницы, исходник и кеш TTS общие, а у читателей в коллекции только ссылка на неё.
+const LibraryOwner = "@library"
+
+// LibraryBook — книга каталога; Added — она уже есть в коллекции пользователя
+type LibraryBook struct {
+	Book
+	Added bool `json:"added"`
+}
+
+// ContentHash — хеш текста книги, по которому повторная загрузка узнаёт книгу каталога
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// hasLibraryRef — условие, что книга b есть в коллекции логина в параметре param по ссылке
+func hasLibraryRef(param string) string {
+	return `EXISTS (
+		SELECT 1 FROM library_refs l
+		WHERE l.book_id = b.bookId AND l.login = ` + param + `
+	)`
+}
+
+func scanLibraryBooks(rows *sql.Rows) ([]LibraryBook, error) {
+	defer rows.Close()
+
+	books := []LibraryBook{}
+	for rows.Next() {
+		b := LibraryBook{Book: Book{Library: true}}
+		var author sql.NullString
+		if err := rows.Scan(&b.BookID, &b.Login, &b.UploadedTs, &b.BookUrl, &b.Title, &author, &b.Added); err != nil {
+			return nil, err
+		}
+		b.Author = author.String
+		books = append(books, b)
+	}
+	return books, rows.Err()
+}
+
+// PublishBook переносит книгу в каталог. Прежний владелец и те, кому она была открыта,
+// сохраняют её в коллекции по ссылке; доступы и приглашения больше не нужны и удаляются.
+// Книгу с тем же текстом второй раз опубликовать нельзя.
+func (s *Storage) PublishBook(ctx context.Context, bookID int64) (Book, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Book{}, err
+	}
+	defer tx.Rollback()
+
+	var (
+		owner string
+		hash  sql.NullString
+	)
+	err = tx.QueryRowContext(ctx,
+		"SELECT login, content_hash FROM books WHERE bookId = $1 FOR UPDATE", bookID,
+	).Scan(&owner, &hash)
+	if err != nil {
+		return Book{}, notFound(err, ErrBookNotFound)
+	}
+	if owner == LibraryOwner {
+		return Book{}, ErrAlreadyPublished
+	}
+	if hash.Valid {
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM books WHERE login = $1 AND content_hash = $2)", LibraryOwner, hash.String,
+		).Scan(&exists); err != nil {
+			return Book{}, err
+		}
+		if exists {
+			return Book{}, ErrAlreadyPublished
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE books SET login = $1 WHERE bookId = $2", LibraryOwner, bookID); err != nil {
+		return Book{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO library_refs (login, book_id)
+		SELECT $1::TEXT, $2::BIGINT
+		UNION
+		SELECT login, book_id FROM book_shares
+		WHERE book_id = $2 AND login IS NOT NULL
+		  AND (expires_ts IS NULL OR expires_ts > extract(epoch from now())::BIGINT)
+		ON CONFLICT DO NOTHING
+	`, owner, bookID); err != nil {
+		return Book{}, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM book_shares WHERE book_id = $1", bookID); err != nil {
+		return Book{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Book{}, err
+	}
+	return s.GetBook(ctx, bookID, owner)
+}
+
+// GetLibrary — весь каталог с отметкой, какие книги уже в коллекции login
+func (s *Storage) GetLibrary(ctx context.Context, login string) ([]LibraryBook, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT b.bookId, b.login, b.uploadedTs, b.bookUrl, b.title, b.author, `+hasLibraryRef("$2")+`
+		FROM books b
+		WHERE b.login = $1
+		ORDER BY b.title, b.bookId
+	`, LibraryOwner, login)
+	if err != nil {
+		return nil, err
+	}
+	return scanLibraryBooks(rows)
+}
+
+// GetLibraryRefs — книги каталога, добавленные в коллекцию login
+func (s *Storage) GetLibraryRefs(ctx context.Context, login string) ([]Book, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT b.bookId, b.login, b.uploadedTs, b.bookUrl, b.title, b.author, TRUE
+		FROM library_refs l JOIN books b ON b.bookId = l.book_id
+		WHERE l.login = $1 AND b.login = $2
+		ORDER BY l.added_ts, b.bookId
+	`, login, LibraryOwner)
+	if err != nil {
+		return nil, err
+	}
+	lb, err := scanLibraryBooks(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var books []Book
+	for _, b := range lb {
+		books = append(books, b.Book)
+	}
+	return books, nil
+}
+
+// FindLibraryBook ищет в каталоге книгу с таким же текстом
+func (s *Storage) FindLibraryBook(ctx context.Context, contentHash string) (int64, error) {
+	var bookID int64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT bookId FROM books WHERE login = $1 AND content_hash = $2 ORDER BY bookId LIMIT 1",
+		LibraryOwner, contentHash,
+	).Scan(&bookID)
+	return bookID, notFound(err, ErrBookNotFound)
+}
+
+// AddLibraryRef добавляет книгу каталога в коллекцию login; повторное добавление ничего не меняет
+func (s *Storage) AddLibraryRef(ctx context.Context, login string, bookID int64) (Book, error) {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO library_refs (login, book_id)
+		SELECT $1, bookId FROM books WHERE bookId = $2 AND login = $3
+		ON CONFLICT DO NOTHING
+	`, login, bookID, LibraryOwner)
+	if err != nil {
+		return Book{}, err
+	}
+	// книги нет в каталоге — своя или открытая книга с тем же id сюда не подходит
+	b, err := s.GetBook(ctx, bookID, login)
+	if err == nil && !b.Library {
+		err = ErrBookNotFound
+	}
+	return b, err
+}
+
+// RemoveLibraryRef убирает книгу каталога из коллекции login вместе с его настройками
+// и прогрессом по ней; сама книга остаётся в каталоге
+func (s *Storage) RemoveLibraryRef(ctx context.Context, login string, bookID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM library_refs WHERE login = $1 AND book_id = $2", login, bookID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrBookNotFound
+	}
+	for _, q := range []string{
+		"DELETE FROM book_settings WHERE login = $1 AND book_id = $2",
+		"DELETE FROM user_progress WHERE login = $1 AND book_id = $2",
+	} {
+		if _, err := tx.ExecContext(ctx, q, login, bookID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
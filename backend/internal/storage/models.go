@@ -8,6 +8,10 @@ type Book struct {
 	BookUrl   string    `db:"bookUrl" json:"bookUrl"`
 	Title   string    `db:"title" json:"title"`
 	Author   string    `db:"author" json:"author"`
+	// Shared — книга другого пользователя, открытая для чтения
+	Shared bool `json:"shared"`
+	// Library — книга публичного каталога, в коллекции по ссылке
+	Library bool `json:"library"`
 }
 
 type PostBookRequest struct {
@@ -23,7 +27,7 @@ type BookPage struct {
 }
 
 type UserProgress struct {
-	Login  string
-	BookID int64
-	PageID int64
+	Login  string `json:"-"`
+	BookID int64  `json:"bookId"`
+	PageID int64  `json:"pageId"`
 }
@@ -1,6 +1,9 @@
 package storage
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
 type Page struct {
 	ID       int64
@@ -10,9 +13,9 @@ type Page struct {
 	AudioURL sql.NullString
 }
 
-func (s *Storage) GetPage(bookID, pageIndex int64) (Page, error) {
+func (s *Storage) GetPage(ctx context.Context, bookID, pageIndex int64) (Page, error) {
 	var p Page
-	err := s.db.QueryRow(`
+	err := s.db.QueryRowContext(ctx, `
 		SELECT id, book_id, page_index, text, audio_url
 		FROM book_pages
 		WHERE book_id = $1 AND page_index = $2
@@ -23,13 +26,13 @@ func (s *Storage) GetPage(bookID, pageIndex int64) (Page, error) {
 		&p.Text,
 		&p.AudioURL,
 	)
-	return p, err
+	return p, notFound(err, ErrPageNotFound)
 }
 
 
-func (s *Storage) GetNextPage(bookID, currentIndex int64) (*int64, error) {
+func (s *Storage) GetNextPage(ctx context.Context, bookID, currentIndex int64) (*int64, error) {
 	var next int64
-	err := s.db.QueryRow(`
+	err := s.db.QueryRowContext(ctx, `
 		SELECT page_index FROM book_pages
 		WHERE book_id = $1 AND page_index > $2
 		ORDER BY page_index ASC
@@ -41,9 +44,9 @@ func (s *Storage) GetNextPage(bookID, currentIndex int64) (*int64, error) {
 	return &next, err
 }
 
-func (s *Storage) GetPrevPage(bookID, currentIndex int64) (*int64, error) {
+func (s *Storage) GetPrevPage(ctx context.Context, bookID, currentIndex int64) (*int64, error) {
 	var prev int64
-	err := s.db.QueryRow(`
+	err := s.db.QueryRowContext(ctx, `
 		SELECT page_index FROM book_pages
 		WHERE book_id = $1 AND page_index < $2
 		ORDER BY page_index DESC
@@ -56,9 +59,9 @@ func (s *Storage) GetPrevPage(bookID, currentIndex int64) (*int64, error) {
 }
 
 
-func (s *Storage) GetFirstPage(bookID int64) (*int64, error) {
+func (s *Storage) GetFirstPage(ctx context.Context, bookID int64) (*int64, error) {
 	var id int64
-	err := s.db.QueryRow(`
+	err := s.db.QueryRowContext(ctx, `
 		SELECT id FROM book_pages
 		WHERE book_id = $1
 		ORDER BY id ASC
@@ -71,17 +74,17 @@ func (s *Storage) GetFirstPage(bookID int64) (*int64, error) {
 }
 
 
-func (s *Storage) GetUserProgress(login string, bookID int64) (int64, error) {
+func (s *Storage) GetUserProgress(ctx context.Context, login string, bookID int64) (int64, error) {
 	var pageID int64
-	err := s.db.QueryRow(`
+	err := s.db.QueryRowContext(ctx, `
 		SELECT page_id FROM user_progress
 		WHERE login=$1 AND book_id=$2
 	`, login, bookID).Scan(&pageID)
-	return pageID, err
+	return pageID, notFound(err, ErrProgressNotFound)
 }
 
-func (s *Storage) SetUserProgress(login string, bookID, pageID int64) error {
-	_, err := s.db.Exec(`
+func (s *Storage) SetUserProgress(ctx context.Context, login string, bookID, pageID int64) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO user_progress (login, book_id, page_id)
 		VALUES ($1, $2, $3)
 		ON CONFLICT (login, book_id)
@@ -90,10 +93,33 @@ func (s *Storage) SetUserProgress(login string, bookID, pageID int64) error {
 	return err
 }
 
-func (s *Storage) GetPageText(bookID int64, pageID int, login string) (string, error) {
+// GetAllUserProgress возвращает прогресс пользователя по всем книгам
+func (s *Storage) GetAllUserProgress(ctx context.Context, login string) ([]UserProgress, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT login, book_id, page_id FROM user_progress
+		WHERE login = $1
+		ORDER BY book_id
+	`, login)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []UserProgress{}
+	for rows.Next() {
+		var p UserProgress
+		if err := rows.Scan(&p.Login, &p.BookID, &p.PageID); err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
+
+func (s *Storage) GetPageText(ctx context.Context, bookID int64, pageID int, login string) (string, error) {
 	var text string
 
-	err := s.db.QueryRow(`
+	err := s.db.QueryRowContext(ctx, `
 		SELECT bp.text
 		FROM book_pages bp
 		JOIN books b ON b.bookId = bp.book_id
@@ -103,15 +129,15 @@ func (s *Storage) GetPageText(bookID int64, pageID int, login string) (string, e
 	`, bookID, pageID, login).Scan(&text)
 
 	if err != nil {
-		return "", err
+		return "", notFound(err, ErrPageNotFound)
 	}
 
 	return text, nil
 }
 
-func (s *Storage) PageExists(bookID, pageIndex int64) (bool, error) {
+func (s *Storage) PageExists(ctx context.Context, bookID, pageIndex int64) (bool, error) {
 	var exists bool
-	err := s.db.QueryRow(`
+	err := s.db.QueryRowContext(ctx, `
 		SELECT EXISTS(
 			SELECT 1 FROM book_pages
 			WHERE book_id = $1 AND page_index = $2
@@ -122,3 +148,27 @@ func (s *Storage) PageExists(bookID, pageIndex int64) (bool, error) {
 	}
 	return exists, nil
 }
+
+// GetBookPages возвращает все страницы книги по порядку
+func (s *Storage) GetBookPages(ctx context.Context, bookID int64) ([]Page, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, book_id, page_index, text, audio_url
+		FROM book_pages
+		WHERE book_id = $1
+		ORDER BY page_index ASC
+	`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pages []Page
+	for rows.Next() {
+		var p Page
+		if err := rows.Scan(&p.ID, &p.BookID, &p.PageIdx, &p.Text, &p.AudioURL); err != nil {
+			return nil, err
+		}
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
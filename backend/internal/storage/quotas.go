@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Quota — лимиты пользователя; 0 — без ограничения
+type Quota struct {
+	MaxBytes     int64 `json:"maxBytes"`
+	MaxBooks     int64 `json:"maxBooks"`
+	MonthlyChars int64 `json:"monthlyChars"`
+}
+
+// QuotaOverride — лимиты конкретного пользователя; nil означает "как по умолчанию"
+type QuotaOverride struct {
+	MaxBytes     *int64 `json:"maxBytes"`
+	MaxBooks     *int64 `json:"maxBooks"`
+	MonthlyChars *int64 `json:"monthlyChars"`
+}
+
+// Usage — занятое место и синтезированные за месяц символы
+type Usage struct {
+	Month            string `json:"month"`
+	StoredBytes      int64  `json:"storedBytes"`
+	Books            int64  `json:"books"`
+	SynthesizedChars int64  `json:"synthesizedChars"`
+}
+
+// UsageMonth — месяц учёта синтеза для момента t
+func UsageMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// GetQuota накладывает переопределения пользователя на лимиты по умолчанию
+func (s *Storage) GetQuota(ctx context.Context, login string, defaults Quota) (Quota, error) {
+	var maxBytes, maxBooks, monthlyChars sql.NullInt64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT max_bytes, max_books, monthly_chars FROM user_quotas WHERE login = $1", login,
+	).Scan(&maxBytes, &maxBooks, &monthlyChars)
+	if errors.Is(err, sql.ErrNoRows) {
+		return defaults, nil
+	}
+	if err != nil {
+		return defaults, err
+	}
+
+	q := defaults
+	if maxBytes.Valid {
+		q.MaxBytes = maxBytes.Int64
+	}
+	if maxBooks.Valid {
+		q.MaxBooks = maxBooks.Int64
+	}
+	if monthlyChars.Valid {
+		q.MonthlyChars = monthlyChars.Int64
+	}
+	return q, nil
+}
+
+func (s *Storage) SetQuotaOverride(ctx context.Context, login string, o QuotaOverride) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_quotas (login, max_bytes, max_books, monthly_chars)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (login)
+		DO UPDATE SET max_bytes = EXCLUDED.max_bytes,
+			max_books = EXCLUDED.max_books,
+			monthly_chars = EXCLUDED.monthly_chars
+	`, login, o.MaxBytes, o.MaxBooks, o.MonthlyChars)
+	return err
+}
+
+// GetUsage считает место так же, как список пользователей в админке: исходники книг
+// плюс собранные экспорты. month — "2006-01" в UTC.
+func (s *Storage) GetUsage(ctx context.Context, login, month string) (Usage, error) {
+	u := Usage{Month: month}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT count(*) FROM books WHERE login = $1),
+			(SELECT COALESCE(sum(size_bytes), 0) FROM books WHERE login = $1) +
+			(SELECT COALESCE(sum(size_bytes), 0) FROM export_jobs WHERE login = $1),
+			COALESCE((SELECT chars FROM tts_usage WHERE login = $1 AND month = $2), 0)
+	`, login, month).Scan(&u.Books, &u.StoredBytes, &u.SynthesizedChars)
+	return u, err
+}
+
+// ReserveSynthesizedChars списывает chars символов за месяц, только если вместе с ними
+// не превышен limit (0 — без ограничения), иначе возвращает ErrTTSQuotaExceeded.
+// Проверка и списание — один запрос, поэтому параллельные синтезы не проходят лимит вдвоём.
+// Символы, которые в итоге не синтезировались, возвращаются через AddSynthesizedChars с минусом.
+func (s *Storage) ReserveSynthesizedChars(ctx context.Context, login, month string, chars, limit int64) error {
+	var used int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO tts_usage AS u (login, month, chars)
+		SELECT $1::TEXT, $2::TEXT, $3::BIGINT
+		WHERE $4::BIGINT = 0 OR $3::BIGINT <= $4::BIGINT
+		ON CONFLICT (login, month)
+		DO UPDATE SET chars = u.chars + EXCLUDED.chars
+		WHERE $4::BIGINT = 0 OR u.chars + EXCLUDED.chars <= $4::BIGINT
+		RETURNING u.chars
+	`, login, month, chars, limit).Scan(&used)
+	return notFound(err, ErrTTSQuotaExceeded)
+}
+
+// AddSynthesizedChars учитывает символы, которые TTS синтезировал, а не взял из кеша
+func (s *Storage) AddSynthesizedChars(ctx context.Context, login, month string, chars int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO tts_usage (login, month, chars)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (login, month)
+		DO UPDATE SET chars = tts_usage.chars + EXCLUDED.chars
+	`, login, month, chars)
+	return err
+}
@@ -1,25 +1,81 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
+
+	"voicebook/internal/logging"
 )
 
-func (s *Storage) GetLoginBySession(sessionID string) (string, error) {
+func (s *Storage) GetLoginBySession(ctx context.Context, sessionID string) (string, error) {
 	var login string
-	err := s.db.QueryRow("SELECT login FROM sessions WHERE session_id=$1", sessionID).Scan(&login)
+	err := s.db.QueryRowContext(ctx, "SELECT login FROM sessions WHERE session_id=$1", sessionID).Scan(&login)
 	if errors.Is(err, sql.ErrNoRows) {
 		return "", nil
 	}
 	return login, err
 }
 
-func (s *Storage) SaveSession(login, sessionID string) error {
-	_, err := s.db.Exec("INSERT INTO sessions (login, session_id) VALUES ($1, $2)", login, sessionID)
+func (s *Storage) SaveSession(ctx context.Context, login, sessionID string) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO sessions (login, session_id) VALUES ($1, $2)", login, sessionID)
+	return err
+}
+
+func (s *Storage) DeleteSession(ctx context.Context, sessionID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE session_id = $1", sessionID)
+	return err
+}
+
+func (s *Storage) SaveRefreshToken(ctx context.Context, login, tokenHash string, expires time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO refresh_tokens (token_hash, login, expires_ts) VALUES ($1, $2, $3)",
+		tokenHash, login, expires.Unix(),
+	)
+	return err
+}
+
+// UseRefreshToken отзывает действующий refresh-токен и возвращает его владельца.
+// Повторное предъявление отозванного токена значит, что его украли: тогда
+// отзываются все refresh-токены пользователя.
+func (s *Storage) UseRefreshToken(ctx context.Context, tokenHash string) (string, error) {
+	var login string
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE refresh_tokens SET revoked = TRUE
+		WHERE token_hash = $1 AND NOT revoked AND expires_ts > $2
+		RETURNING login
+	`, tokenHash, time.Now().Unix()).Scan(&login)
+	if err == nil {
+		return login, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	err = s.db.QueryRowContext(ctx,
+		"SELECT login FROM refresh_tokens WHERE token_hash = $1 AND revoked",
+		tokenHash,
+	).Scan(&login)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", ErrRefreshTokenInvalid
+	case err != nil:
+		return "", err
+	}
+	logging.FromContext(ctx).Warn("revoked refresh token reused, revoking all user tokens", "token_login", login)
+	if err := s.RevokeRefreshTokens(ctx, login); err != nil {
+		return "", err
+	}
+	return "", ErrRefreshTokenInvalid
+}
+
+func (s *Storage) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked = TRUE WHERE token_hash = $1", tokenHash)
 	return err
 }
 
-func (s *Storage) DeleteSession(sessionID string) error {
-	_, err := s.db.Exec("DELETE FROM sessions WHERE session_id = $1", sessionID)
+func (s *Storage) RevokeRefreshTokens(ctx context.Context, login string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked = TRUE WHERE login = $1", login)
 	return err
 }
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Значения по умолчанию совпадают с настройками TTS-сервиса
+const (
+	DefaultVoice      = "ermil"
+	DefaultRole       = "neutral"
+	DefaultSpeed      = 1.0
+	DefaultPitchShift = 0
+	DefaultFormat     = "wav"
+)
+
+type VoiceSettings struct {
+	Voice      string  `json:"voice"`
+	Role       string  `json:"role"`
+	Speed      float64 `json:"speed"`
+	PitchShift int     `json:"pitchShift"`
+	Format     string  `json:"format"`
+}
+
+// BookVoiceSettings — переопределения для конкретной книги, nil означает "как в настройках пользователя"
+type BookVoiceSettings struct {
+	Voice      *string  `json:"voice"`
+	Role       *string  `json:"role"`
+	Speed      *float64 `json:"speed"`
+	PitchShift *int     `json:"pitchShift"`
+	Format     *string  `json:"format"`
+}
+
+func DefaultVoiceSettings() VoiceSettings {
+	return VoiceSettings{
+		Voice:      DefaultVoice,
+		Role:       DefaultRole,
+		Speed:      DefaultSpeed,
+		PitchShift: DefaultPitchShift,
+		Format:     DefaultFormat,
+	}
+}
+
+// Apply накладывает переопределения книги на настройки пользователя
+func (o BookVoiceSettings) Apply(s VoiceSettings) VoiceSettings {
+	if o.Voice != nil {
+		s.Voice = *o.Voice
+	}
+	if o.Role != nil {
+		s.Role = *o.Role
+	}
+	if o.Speed != nil {
+		s.Speed = *o.Speed
+	}
+	if o.PitchShift != nil {
+		s.PitchShift = *o.PitchShift
+	}
+	if o.Format != nil {
+		s.Format = *o.Format
+	}
+	return s
+}
+
+func (s *Storage) GetUserSettings(ctx context.Context, login string) (VoiceSettings, error) {
+	var vs VoiceSettings
+	err := s.db.QueryRowContext(ctx, `
+		SELECT voice, role, speed, pitch_shift, format
+		FROM user_settings
+		WHERE login = $1
+	`, login).Scan(&vs.Voice, &vs.Role, &vs.Speed, &vs.PitchShift, &vs.Format)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultVoiceSettings(), nil
+	}
+	return vs, err
+}
+
+func (s *Storage) SetUserSettings(ctx context.Context, login string, vs VoiceSettings) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_settings (login, voice, role, speed, pitch_shift, format)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (login)
+		DO UPDATE SET voice = EXCLUDED.voice,
+			role = EXCLUDED.role,
+			speed = EXCLUDED.speed,
+			pitch_shift = EXCLUDED.pitch_shift,
+			format = EXCLUDED.format
+	`, login, vs.Voice, vs.Role, vs.Speed, vs.PitchShift, vs.Format)
+	return err
+}
+
+func (s *Storage) GetBookSettings(ctx context.Context, login string, bookID int64) (BookVoiceSettings, error) {
+	o, err := scanBookSettings(s.db.QueryRowContext(ctx, `
+		SELECT voice, role, speed, pitch_shift, format
+		FROM book_settings
+		WHERE login = $1 AND book_id = $2
+	`, login, bookID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return BookVoiceSettings{}, nil
+	}
+	return o, err
+}
+
+// GetAllBookSettings возвращает переопределения всех книг пользователя по bookId
+func (s *Storage) GetAllBookSettings(ctx context.Context, login string) (map[int64]BookVoiceSettings, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT book_id, voice, role, speed, pitch_shift, format
+		FROM book_settings
+		WHERE login = $1
+	`, login)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[int64]BookVoiceSettings)
+	for rows.Next() {
+		var bookID int64
+		o, err := scanBookSettings(rows, &bookID)
+		if err != nil {
+			return nil, err
+		}
+		res[bookID] = o
+	}
+	return res, rows.Err()
+}
+
+// scanBookSettings читает переопределения; колонки перед ними сканируются в head
+func scanBookSettings(row scanner, head ...any) (BookVoiceSettings, error) {
+	var (
+		o          BookVoiceSettings
+		voice      sql.NullString
+		role       sql.NullString
+		speed      sql.NullFloat64
+		pitchShift sql.NullInt64
+		format     sql.NullString
+	)
+	if err := row.Scan(append(head, &voice, &role, &speed, &pitchShift, &format)...); err != nil {
+		return o, err
+	}
+
+	if voice.Valid {
+		o.Voice = &voice.String
+	}
+	if role.Valid {
+		o.Role = &role.String
+	}
+	if speed.Valid {
+		o.Speed = &speed.Float64
+	}
+	if pitchShift.Valid {
+		p := int(pitchShift.Int64)
+		o.PitchShift = &p
+	}
+	if format.Valid {
+		o.Format = &format.String
+	}
+	return o, nil
+}
+
+func (s *Storage) SetBookSettings(ctx context.Context, login string, bookID int64, o BookVoiceSettings) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO book_settings (login, book_id, voice, role, speed, pitch_shift, format)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (login, book_id)
+		DO UPDATE SET voice = EXCLUDED.voice,
+			role = EXCLUDED.role,
+			speed = EXCLUDED.speed,
+			pitch_shift = EXCLUDED.pitch_shift,
+			format = EXCLUDED.format
+	`, login, bookID, o.Voice, o.Role, o.Speed, o.PitchShift, o.Format)
+	return err
+}
+
+// GetEffectiveSettings возвращает итоговые настройки озвучки книги для пользователя
+func (s *Storage) GetEffectiveSettings(ctx context.Context, login string, bookID int64) (VoiceSettings, error) {
+	vs, err := s.GetUserSettings(ctx, login)
+	if err != nil {
+		return vs, err
+	}
+	o, err := s.GetBookSettings(ctx, login, bookID)
+	if err != nil {
+		return vs, err
+	}
+	return o.Apply(vs), nil
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// BookShare — доступ на чтение к книге: либо конкретному логину, либо по ссылке-приглашению.
+// Приглашение можно принять несколько раз, пока оно не истекло; принявший получает
+// обычный доступ по логину.
+type BookShare struct {
+	ID        int64  `json:"shareId"`
+	BookID    int64  `json:"bookId"`
+	Login     string `json:"login,omitempty"`
+	Invite    bool   `json:"invite"`
+	ExpiresTs *int64 `json:"expiresTs"`
+	CreatedTs int64  `json:"createdTs"`
+}
+
+const shareColumns = `id, book_id, COALESCE(login, ''), token_hash IS NOT NULL, expires_ts, created_ts`
+
+// hasActiveShare — условие действующего доступа к книге b для логина в параметре param
+func hasActiveShare(param string) string {
+	return `EXISTS (
+		SELECT 1 FROM book_shares s
+		WHERE s.book_id = b.bookId AND s.login = ` + param + `
+		  AND (s.expires_ts IS NULL OR s.expires_ts > extract(epoch from now())::BIGINT)
+	)`
+}
+
+func scanBookShare(row scanner) (BookShare, error) {
+	var sh BookShare
+	err := row.Scan(&sh.ID, &sh.BookID, &sh.Login, &sh.Invite, &sh.ExpiresTs, &sh.CreatedTs)
+	return sh, err
+}
+
+func expiresUnix(expires time.Time) *int64 {
+	if expires.IsZero() {
+		return nil
+	}
+	ts := expires.Unix()
+	return &ts
+}
+
+// ShareBook даёт доступ на чтение пользователю login; повторный вызов обновляет срок
+func (s *Storage) ShareBook(ctx context.Context, bookID int64, login string, expires time.Time) (BookShare, error) {
+	sh, err := scanBookShare(s.db.QueryRowContext(ctx, `
+		INSERT INTO book_shares (book_id, login, expires_ts)
+		SELECT $1, u.login, $3 FROM users u WHERE u.login = $2
+		ON CONFLICT (book_id, login) WHERE login IS NOT NULL
+		DO UPDATE SET expires_ts = EXCLUDED.expires_ts
+		RETURNING `+shareColumns,
+		bookID, login, expiresUnix(expires),
+	))
+	return sh, notFound(err, ErrUserNotFound)
+}
+
+// CreateInvite сохраняет хеш токена ссылки-приглашения
+func (s *Storage) CreateInvite(ctx context.Context, bookID int64, tokenHash string, expires time.Time) (BookShare, error) {
+	return scanBookShare(s.db.QueryRowContext(ctx, `
+		INSERT INTO book_shares (book_id, token_hash, expires_ts)
+		VALUES ($1, $2, $3)
+		RETURNING `+shareColumns,
+		bookID, tokenHash, expiresUnix(expires),
+	))
+}
+
+func (s *Storage) GetBookShares(ctx context.Context, bookID int64) ([]BookShare, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+shareColumns+`
+		FROM book_shares
+		WHERE book_id = $1
+		ORDER BY id
+	`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []BookShare{}
+	for rows.Next() {
+		sh, err := scanBookShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, sh)
+	}
+	return shares, rows.Err()
+}
+
+// DeleteBookShare отзывает доступ или приглашение
+func (s *Storage) DeleteBookShare(ctx context.Context, bookID, shareID int64) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM book_shares WHERE id = $1 AND book_id = $2", shareID, bookID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrShareNotFound
+	}
+	return err
+}
+
+// AcceptInvite даёт принявшему приглашение доступ к книге на срок приглашения
+// и возвращает id книги. Владельцу доступ не нужен, приглашение для него ничего не меняет.
+func (s *Storage) AcceptInvite(ctx context.Context, tokenHash, login string) (int64, error) {
+	var (
+		bookID  int64
+		owner   string
+		expires sql.NullInt64
+	)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT s.book_id, b.login, s.expires_ts
+		FROM book_shares s JOIN books b ON b.bookId = s.book_id
+		WHERE s.token_hash = $1
+		  AND (s.expires_ts IS NULL OR s.expires_ts > extract(epoch from now())::BIGINT)
+	`, tokenHash).Scan(&bookID, &owner, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInviteInvalid
+	}
+	if err != nil || owner == login {
+		return bookID, err
+	}
+
+	var exp *int64
+	if expires.Valid {
+		exp = &expires.Int64
+	}
+	// уже выданный доступ приглашение не сокращает
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO book_shares (book_id, login, expires_ts)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (book_id, login) WHERE login IS NOT NULL
+		DO UPDATE SET expires_ts = CASE
+			WHEN book_shares.expires_ts IS NULL OR EXCLUDED.expires_ts IS NULL THEN NULL
+			ELSE GREATEST(book_shares.expires_ts, EXCLUDED.expires_ts)
+		END
+	`, bookID, login, exp)
+	return bookID, err
+}
+
+// GetSharedBooks — книги других пользователей, к которым у login есть доступ
+func (s *Storage) GetSharedBooks(ctx context.Context, login string) ([]Book, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT b.bookId, b.login, b.uploadedTs, b.bookUrl, b.title, b.author
+		FROM books b
+		WHERE b.login <> $1 AND `+hasActiveShare("$1")+`
+		ORDER BY b.bookId
+	`, login)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []Book
+	for rows.Next() {
+		b := Book{Shared: true}
+		if err := rows.Scan(&b.BookID, &b.Login, &b.UploadedTs, &b.BookUrl, &b.Title, &b.Author); err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+	return books, rows.Err()
+}
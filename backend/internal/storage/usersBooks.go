@@ -1,36 +1,43 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"voicebook/internal/logging"
 	"voicebook/internal/utils"
+
+	"github.com/lib/pq"
 )
 
 type Storage struct {
 	db *sql.DB
 }
 
-
-
-func (s *Storage) CreateUser(login, password string) error {
-	_, err := s.db.Exec("INSERT INTO users (login, password) VALUES ($1, $2)", login, password)
+func (s *Storage) CreateUser(ctx context.Context, login, password string) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO users (login, password) VALUES ($1, $2)", login, password)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrUserExists
+	}
 	if err != nil {
 		return fmt.Errorf("failed to insert user: %w", err)
 	}
 	return nil
 }
 
-func (s *Storage) GetUser(login, password string) (bool, error) {
+func (s *Storage) GetUser(ctx context.Context, login, password string) (bool, error) {
 	var exists bool
-	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE login=$1 AND password=$2)", login, password).Scan(&exists)
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE login=$1 AND password=$2)", login, password).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
 	return exists, nil
 }
 
-func (s *Storage) GetUserBooks(login string) ([]Book, error) {
-	rows, err := s.db.Query(`
+func (s *Storage) GetUserBooks(ctx context.Context, login string) ([]Book, error) {
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT bookId, login, uploadedTs, bookUrl, title, author
 		FROM books
 		WHERE login = $1
@@ -58,15 +65,16 @@ func (s *Storage) GetUserBooks(login string) ([]Book, error) {
 	return books, nil
 }
 
-
-func (s *Storage) GetBook(bookID int64, login string) (Book, error) {
+// GetBook возвращает книгу, которую login может читать: свою, открытую ему
+// другим пользователем (тогда Shared = true) или книгу каталога из его коллекции (Library = true)
+func (s *Storage) GetBook(ctx context.Context, bookID int64, login string) (Book, error) {
 	var b Book
 
-	err := s.db.QueryRow(
-		`SELECT bookId, login, uploadedTs, bookUrl, title, author
-		 FROM books
-		 WHERE bookId = $1 AND login = $2`,
-		bookID, login,
+	err := s.db.QueryRowContext(ctx,
+		`SELECT bookId, login, uploadedTs, bookUrl, title, author, login NOT IN ($2, $3), login = $3
+		 FROM books b
+		 WHERE bookId = $1 AND (login = $2 OR `+hasActiveShare("$2")+` OR `+hasLibraryRef("$2")+`)`,
+		bookID, login, LibraryOwner,
 	).Scan(
 		&b.BookID,
 		&b.Login,
@@ -74,25 +82,26 @@ func (s *Storage) GetBook(bookID int64, login string) (Book, error) {
 		&b.BookUrl,
 		&b.Title,
 		&b.Author,
+		&b.Shared,
+		&b.Library,
 	)
 
-	return b, err
+	return b, notFound(err, ErrBookNotFound)
 }
 
-
-func (s *Storage) AddBook(login, bookURL, title, author, fullText string) (Book, error) {
-	tx, err := s.db.Begin()
+func (s *Storage) AddBook(ctx context.Context, login, bookURL, title, author, fullText string) (Book, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return Book{}, err
 	}
 	defer tx.Rollback()
 
 	var b Book
-	err = tx.QueryRow(
-		`INSERT INTO books (login, bookUrl, title, author, uploadedTs)
-		 VALUES ($1, $2, $3, $4, extract(epoch from now())::BIGINT)
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO books (login, bookUrl, title, author, size_bytes, content_hash, uploadedTs)
+		 VALUES ($1, $2, $3, $4, $5, $6, extract(epoch from now())::BIGINT)
 		 RETURNING bookId, uploadedTs`,
-		login, bookURL, title, author,
+		login, bookURL, title, author, len(fullText), ContentHash(fullText),
 	).Scan(&b.BookID, &b.UploadedTs)
 	if err != nil {
 		return Book{}, err
@@ -107,7 +116,7 @@ func (s *Storage) AddBook(login, bookURL, title, author, fullText string) (Book,
 
 	for i, text := range pages {
 		// +1 чтобы первая страница была 1
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			`INSERT INTO book_pages (book_id, page_index, text)
 			 VALUES ($1, $2, $3)`,
 			b.BookID, i+1, text,
@@ -120,14 +129,62 @@ func (s *Storage) AddBook(login, bookURL, title, author, fullText string) (Book,
 	if err = tx.Commit(); err != nil {
 		return Book{}, err
 	}
+	logging.FromContext(ctx).Debug("book saved", "book_id", b.BookID, "pages", len(pages))
 
 	return b, nil
 }
 
+// DeleteBook одной транзакцией удаляет книгу владельца со всем, что на неё ссылается:
+// страницами, прогрессом и настройками всех читателей, доступами и экспортами.
+// Возвращает URL файлов экспортов в S3, удаляет их вызывающий: иначе они продолжали бы
+// занимать место пользователя.
+func (s *Storage) DeleteBook(ctx context.Context, bookID int64, login string) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
+	res, err := tx.ExecContext(ctx, "DELETE FROM books WHERE bookId=$1 AND login=$2", bookID, login)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrBookNotFound
+	}
 
+	for _, q := range []string{
+		"DELETE FROM book_pages WHERE book_id = $1",
+		"DELETE FROM book_settings WHERE book_id = $1",
+		"DELETE FROM user_progress WHERE book_id = $1",
+		"DELETE FROM book_shares WHERE book_id = $1",
+		"DELETE FROM library_refs WHERE book_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, q, bookID); err != nil {
+			return nil, err
+		}
+	}
 
-func (s *Storage) DeleteBook(bookID int64, login string) error {
-	_, err := s.db.Exec("DELETE FROM books WHERE bookId=$1 AND login=$2", bookID, login)
-	return err
+	var urls []string
+	rows, err := tx.QueryContext(ctx, "DELETE FROM export_jobs WHERE book_id = $1 RETURNING file_url", bookID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return urls, tx.Commit()
 }
@@ -0,0 +1,130 @@
+package timing
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"voicebook/internal/ssml"
+)
+
+// Span — фрагмент текста страницы; Start и End — смещения в символах (рунах), End не включается
+type Span struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"-"`
+}
+
+// Meta — метаданные синтеза, которые TTS-сервис кладёт рядом с аудио
+type Meta struct {
+	DurationMs int64 `json:"duration_ms"`
+	Segments   []struct {
+		StartMs int64 `json:"start_ms"`
+		EndMs   int64 `json:"end_ms"`
+	} `json:"segments"`
+}
+
+type Word struct {
+	Start   int   `json:"start"`
+	End     int   `json:"end"`
+	StartMs int64 `json:"startMs"`
+	EndMs   int64 `json:"endMs"`
+}
+
+type Sentence struct {
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	StartMs int64  `json:"startMs"`
+	EndMs   int64  `json:"endMs"`
+	Words   []Word `json:"words"`
+}
+
+// Sentences делит текст страницы так же, как TTS-клиент делит её на сегменты синтеза
+// (ssml.Segments): i-й фрагмент соответствует i-му сегменту в метаданных.
+// Разделители сцен и заголовок главы, которого нет на странице, дают фрагменты
+// нулевой длины: в ответ они не попадают, но сохраняют соответствие сегментам.
+func Sentences(text, heading string) []Span {
+	segs := ssml.Segments(text, heading)
+	spans := make([]Span, len(segs))
+	for i, seg := range segs {
+		spans[i] = Span{Start: seg.Start, End: seg.End, Text: seg.Text}
+	}
+	return spans
+}
+
+// Marks сопоставляет предложения с временем из метаданных синтеза; время слов
+// внутри предложения оценивается пропорционально их длине
+func Marks(spans []Span, meta Meta) []Sentence {
+	sentences := make([]Sentence, 0, len(spans))
+	exact := len(meta.Segments) == len(spans)
+
+	var total int
+	for _, s := range spans {
+		total += utf8.RuneCountInString(s.Text)
+	}
+
+	var elapsed int
+	for i, s := range spans {
+		var startMs, endMs int64
+		if exact {
+			startMs, endMs = meta.Segments[i].StartMs, meta.Segments[i].EndMs
+		} else if total > 0 {
+			// сегментов нет (старый кеш) — делим общую длительность по длине текста
+			n := utf8.RuneCountInString(s.Text)
+			startMs = meta.DurationMs * int64(elapsed) / int64(total)
+			endMs = meta.DurationMs * int64(elapsed+n) / int64(total)
+			elapsed += n
+		}
+		if s.Start == s.End {
+			continue
+		}
+		sentences = append(sentences, Sentence{
+			Start:   s.Start,
+			End:     s.End,
+			StartMs: startMs,
+			EndMs:   endMs,
+			Words:   words(s, startMs, endMs),
+		})
+	}
+	return sentences
+}
+
+func words(s Span, startMs, endMs int64) []Word {
+	runes := []rune(s.Text)
+
+	var (
+		spans []Span
+		total int
+	)
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && !unicode.IsSpace(runes[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			spans = append(spans, Span{Start: start, End: i})
+			total += i - start
+			start = -1
+		}
+	}
+
+	res := make([]Word, 0, len(spans))
+	if total == 0 {
+		return res
+	}
+	duration := endMs - startMs
+	elapsed := 0
+	for _, w := range spans {
+		n := w.End - w.Start
+		res = append(res, Word{
+			Start:   s.Start + w.Start,
+			End:     s.Start + w.End,
+			StartMs: startMs + duration*int64(elapsed)/int64(total),
+			EndMs:   startMs + duration*int64(elapsed+n)/int64(total),
+		})
+		elapsed += n
+	}
+	return res
+}
@@ -0,0 +1,210 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalid — токен испорчен, подписан неизвестным ключом или истёк
+var ErrInvalid = errors.New("invalid token")
+
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key — ключ подписи. kid попадает в заголовок токена, поэтому старые токены
+// проверяются, пока их ключ остаётся в списке.
+type Key struct {
+	ID  string
+	Alg string
+
+	secret  []byte
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// ParseKey разбирает ключ из конфигурации в виде "kid:alg:base64": для HS256 —
+// секрет не короче 32 байт, для EdDSA — seed ключа Ed25519 (32 байта)
+func ParseKey(s string) (Key, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return Key{}, errors.New(`key must look like "kid:alg:base64"`)
+	}
+	k := Key{ID: parts[0], Alg: parts[1]}
+	raw, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Key{}, fmt.Errorf("key %s: %w", k.ID, err)
+	}
+
+	switch k.Alg {
+	case AlgHS256:
+		if len(raw) < 32 {
+			return Key{}, fmt.Errorf("key %s: HS256 secret must be at least 32 bytes", k.ID)
+		}
+		k.secret = raw
+	case AlgEdDSA:
+		if len(raw) != ed25519.SeedSize {
+			return Key{}, fmt.Errorf("key %s: EdDSA seed must be %d bytes", k.ID, ed25519.SeedSize)
+		}
+		k.private = ed25519.NewKeyFromSeed(raw)
+		k.public = k.private.Public().(ed25519.PublicKey)
+	default:
+		return Key{}, fmt.Errorf("key %s: unknown alg %q", k.ID, k.Alg)
+	}
+	return k, nil
+}
+
+func (k Key) sign(data []byte) []byte {
+	if k.Alg == AlgEdDSA {
+		return ed25519.Sign(k.private, data)
+	}
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (k Key) verify(data, sig []byte) bool {
+	if k.Alg == AlgEdDSA {
+		return ed25519.Verify(k.public, data, sig)
+	}
+	return hmac.Equal(k.sign(data), sig)
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// Claims — содержимое access-токена (подмножество JWT)
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Issuer выпускает и проверяет короткоживущие access-токены в формате JWT.
+// Подписывает первый ключ, проверяют все — так ключи ротируются без разлогина.
+type Issuer struct {
+	name string
+	ttl  time.Duration
+	sign Key
+	keys map[string]Key
+}
+
+func NewIssuer(name string, ttl time.Duration, keys []Key) (*Issuer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+	i := &Issuer{name: name, ttl: ttl, sign: keys[0], keys: make(map[string]Key, len(keys))}
+	for _, k := range keys {
+		if _, dup := i.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		i.keys[k.ID] = k
+	}
+	return i, nil
+}
+
+// TTL — время жизни выпускаемых токенов
+func (i *Issuer) TTL() time.Duration {
+	return i.ttl
+}
+
+// Issue подписывает токен для login
+func (i *Issuer) Issue(login string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Issuer:    i.name,
+		Subject:   login,
+		ID:        randomID(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(i.ttl).Unix(),
+	}
+
+	h, err := json.Marshal(header{Alg: i.sign.Alg, Typ: "JWT", Kid: i.sign.ID})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := encode(h) + "." + encode(c)
+	return signed + "." + encode(i.sign.sign([]byte(signed))), nil
+}
+
+// Verify проверяет подпись, издателя и срок действия; любая ошибка — ErrInvalid
+func (i *Issuer) Verify(tok string) (Claims, error) {
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalid
+	}
+
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil {
+		return Claims{}, ErrInvalid
+	}
+	// alg из заголовка должен совпадать с алгоритмом ключа, иначе возможна подмена алгоритма
+	k, ok := i.keys[h.Kid]
+	if !ok || h.Alg != k.Alg {
+		return Claims{}, ErrInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !k.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return Claims{}, ErrInvalid
+	}
+
+	var c Claims
+	if err := decodeJSON(parts[1], &c); err != nil {
+		return Claims{}, ErrInvalid
+	}
+	if c.Issuer != i.name || c.Subject == "" || time.Now().Unix() >= c.ExpiresAt {
+		return Claims{}, ErrInvalid
+	}
+	return c, nil
+}
+
+// NewRefresh генерирует refresh-токен; на сервере хранится только его хеш (Hash)
+func NewRefresh() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func Hash(tok string) string {
+	sum := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(sum[:])
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeJSON(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func randomID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
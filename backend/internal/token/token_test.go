@@ -0,0 +1,178 @@
+package token
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T, id, alg string, fill byte) Key {
+	t.Helper()
+	k, err := ParseKey(id + ":" + alg + ":" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(fill), 32))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func testIssuer(t *testing.T, ttl time.Duration, keys ...Key) *Issuer {
+	t.Helper()
+	i, err := NewIssuer("voicebook", ttl, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+func TestParseKey(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	short := base64.StdEncoding.EncodeToString(make([]byte, 16))
+	for _, tc := range []struct {
+		key string
+		ok  bool
+	}{
+		{"k1:HS256:" + seed, true},
+		{"k1:EdDSA:" + seed, true},
+		{"k1:HS256:" + short, false},
+		{"k1:EdDSA:" + short, false},
+		{"k1:RS256:" + seed, false},
+		{"k1:HS256:not base64!", false},
+		{":HS256:" + seed, false},
+		{"k1:" + seed, false},
+	} {
+		if _, err := ParseKey(tc.key); (err == nil) != tc.ok {
+			t.Errorf("ParseKey(%q): err = %v, want ok = %v", tc.key, err, tc.ok)
+		}
+	}
+}
+
+func TestIssueVerify(t *testing.T) {
+	for _, alg := range []string{AlgHS256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			i := testIssuer(t, time.Minute, testKey(t, "k1", alg, 'a'))
+			tok, err := i.Issue("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := i.Verify(tok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Subject != "alice" || c.Issuer != "voicebook" || c.ExpiresAt <= c.IssuedAt {
+				t.Errorf("unexpected claims %+v", c)
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	hs := testKey(t, "k1", AlgHS256, 'a')
+	ed := testKey(t, "k2", AlgEdDSA, 'b')
+	i := testIssuer(t, time.Minute, hs, ed)
+	tok, err := i.Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(tok, ".")
+
+	expired, err := testIssuer(t, -time.Second, hs).Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIssuer, err := NewIssuer("other", time.Minute, []Key{hs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := otherIssuer.Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSecret, err := testIssuer(t, time.Minute, testKey(t, "k1", AlgHS256, 'z')).Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// kid ключа EdDSA с alg HS256: подмена алгоритма
+	swapped := encode([]byte(`{"alg":"HS256","typ":"JWT","kid":"k2"}`)) + "." + parts[1]
+	swapped += "." + encode(hs.sign([]byte(swapped)))
+
+	for name, tok := range map[string]string{
+		"empty":        "",
+		"two parts":    parts[0] + "." + parts[1],
+		"bad header":   "!!!." + parts[1] + "." + parts[2],
+		"tampered":     parts[0] + "." + encode([]byte(`{"iss":"voicebook","sub":"admin","exp":9999999999}`)) + "." + parts[2],
+		"bad sig":      parts[0] + "." + parts[1] + "." + encode([]byte("nope")),
+		"expired":      expired,
+		"other issuer": foreign,
+		"other secret": otherSecret,
+		"alg swap":     swapped,
+	} {
+		if _, err := i.Verify(tok); err != ErrInvalid {
+			t.Errorf("%s: err = %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+// ротация: новый ключ подписывает, старые токены проверяются, пока старый ключ в списке
+func TestKeyRotation(t *testing.T) {
+	oldKey := testKey(t, "old", AlgHS256, 'a')
+	newKey := testKey(t, "new", AlgEdDSA, 'b')
+
+	oldTok, err := testIssuer(t, time.Minute, oldKey).Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := testIssuer(t, time.Minute, newKey, oldKey)
+	if _, err := rotated.Verify(oldTok); err != nil {
+		t.Errorf("token of the old key after rotation: %v", err)
+	}
+	newTok, err := rotated.Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h header
+	if err := decodeJSON(strings.Split(newTok, ".")[0], &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Kid != "new" || h.Alg != AlgEdDSA {
+		t.Errorf("rotated issuer signs with %+v, want kid new", h)
+	}
+
+	retired := testIssuer(t, time.Minute, newKey)
+	if _, err := retired.Verify(oldTok); err != ErrInvalid {
+		t.Errorf("token of a removed key: err = %v, want ErrInvalid", err)
+	}
+	if _, err := retired.Verify(newTok); err != nil {
+		t.Errorf("token of the current key: %v", err)
+	}
+}
+
+func TestNewIssuer(t *testing.T) {
+	if _, err := NewIssuer("voicebook", time.Minute, nil); err == nil {
+		t.Error("NewIssuer without keys: want error")
+	}
+	k := testKey(t, "k1", AlgHS256, 'a')
+	if _, err := NewIssuer("voicebook", time.Minute, []Key{k, k}); err == nil {
+		t.Error("NewIssuer with duplicate kid: want error")
+	}
+}
+
+// на сервере хранится только хеш refresh-токена: он должен быть детерминированным,
+// а сами токены — не повторяться
+func TestRefresh(t *testing.T) {
+	a, err := NewRefresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewRefresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("NewRefresh returned the same token twice")
+	}
+	if Hash(a) != Hash(a) || Hash(a) == Hash(b) || strings.Contains(Hash(a), a) {
+		t.Error("Hash must be deterministic and hide the token")
+	}
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "voicebook-api"
+
+// Tracer — общий трассировщик для ручных спанов (экспорт, синтез и т.п.)
+func Tracer() trace.Tracer {
+	return otel.Tracer("voicebook")
+}
+
+// Init настраивает экспорт спанов по exporter:
+// otlp — по OTLP/HTTP (адрес берётся из OTEL_EXPORTER_OTLP_ENDPOINT),
+// console — в stdout для локальной отладки, none или пусто — трассировка выключена.
+// Возвращает функцию, которая дописывает оставшиеся спаны при остановке.
+func Init(ctx context.Context, exporterName string) (func(context.Context) error, error) {
+	// trace context передаётся дальше (в TTS) даже с выключенным экспортом
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch exporterName {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "console", "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporterName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	// доля сэмплирования задаётся стандартными OTEL_TRACES_SAMPLER/OTEL_TRACES_SAMPLER_ARG
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
@@ -0,0 +1,158 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Форматы совпадают со значениями storage.VoiceSettings.Format и export_jobs.format.
+// M4B — только для экспорта книги: MP4 нельзя писать в поток, поэтому страниц в нём нет
+const (
+	WAV     = "wav"
+	MP3     = "mp3"
+	OggOpus = "oggopus"
+	M4B     = "m4b"
+)
+
+var ContentTypes = map[string]string{
+	WAV:     "audio/wav",
+	MP3:     "audio/mpeg",
+	OggOpus: "audio/ogg",
+	M4B:     "audio/mp4",
+}
+
+var Extensions = map[string]string{
+	WAV:     "wav",
+	MP3:     "mp3",
+	OggOpus: "ogg",
+	M4B:     "m4b",
+}
+
+// синонимы для ?format= и заголовка Accept
+var aliases = map[string]string{
+	"wav": WAV, "wave": WAV, "audio/wav": WAV, "audio/x-wav": WAV, "audio/wave": WAV,
+	"mp3": MP3, "mpeg": MP3, "audio/mpeg": MP3, "audio/mp3": MP3,
+	"ogg": OggOpus, "opus": OggOpus, "oggopus": OggOpus, "audio/ogg": OggOpus, "audio/opus": OggOpus,
+}
+
+// ParseFormat приводит имя формата или MIME-тип к одному из форматов страниц
+func ParseFormat(s string) (string, bool) {
+	f, ok := aliases[strings.ToLower(strings.TrimSpace(s))]
+	return f, ok
+}
+
+// Negotiate выбирает формат: явный ?format= важнее Accept, а "audio/*" и "*/*"
+// означают формат по умолчанию
+func Negotiate(query, accept, fallback string) (string, error) {
+	if query != "" {
+		f, ok := ParseFormat(query)
+		if !ok {
+			return "", fmt.Errorf("unsupported format %q", query)
+		}
+		return f, nil
+	}
+	if accept == "" {
+		return fallback, nil
+	}
+
+	type candidate struct {
+		format string
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mime, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		mime = strings.TrimSpace(mime)
+		if mime == "*/*" || mime == "audio/*" {
+			candidates = append(candidates, candidate{fallback, q})
+		} else if f, ok := ParseFormat(mime); ok {
+			candidates = append(candidates, candidate{f, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return fallback, nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].format, nil
+}
+
+// ErrNoEncoder — ffmpeg не найден
+var ErrNoEncoder = errors.New("ffmpeg not found")
+
+var ffmpegArgs = map[string][]string{
+	MP3:     {"-c:a", "libmp3lame", "-b:a", "64k", "-f", "mp3"},
+	OggOpus: {"-c:a", "libopus", "-b:a", "32k", "-f", "ogg"},
+	M4B:     {"-c:a", "aac", "-b:a", "64k", "-f", "mp4"},
+}
+
+// FFmpeg перекодирует WAV внешним ffmpeg через stdin/stdout
+type FFmpeg struct {
+	path string
+}
+
+func NewFFmpeg() (*FFmpeg, error) {
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, ErrNoEncoder
+	}
+	return &FFmpeg{path: path}, nil
+}
+
+func (f *FFmpeg) Transcode(ctx context.Context, wav []byte, format string) ([]byte, error) {
+	if format == WAV {
+		return wav, nil
+	}
+	codec, ok := ffmpegArgs[format]
+	if !ok || format == M4B {
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	args := append([]string{"-loglevel", "error", "-f", "wav", "-i", "pipe:0"}, codec...)
+	args = append(args, "pipe:1")
+
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, f.path, args...)
+	cmd.Stdin = bytes.NewReader(wav)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out.Bytes(), nil
+}
+
+// Encode кодирует в файл out то, что описывают входные аргументы ffmpeg: например,
+// список WAV для склейки и файл метаданных с главами
+func (f *FFmpeg) Encode(ctx context.Context, inputArgs []string, format, out string) error {
+	codec, ok := ffmpegArgs[format]
+	if !ok {
+		return fmt.Errorf("unsupported format %q", format)
+	}
+
+	args := append([]string{"-y", "-loglevel", "error"}, inputArgs...)
+	args = append(args, codec...)
+	args = append(args, out)
+
+	cmd := exec.CommandContext(ctx, f.path, args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package transcode
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		name, query, accept, want string
+		wantErr                   bool
+	}{
+		{"default", "", "", MP3, false},
+		{"query", "ogg", "audio/wav", OggOpus, false},
+		{"query is case-insensitive", " WAV ", "", WAV, false},
+		{"query mime type", "audio/mpeg", "", MP3, false},
+		{"unknown query", "flac", "", "", true},
+		{"m4b is not a page format", "m4b", "", "", true},
+		{"accept", "", "audio/ogg", OggOpus, false},
+		{"accept by quality", "", "audio/mpeg;q=0.5, audio/wav;q=0.9", WAV, false},
+		{"first of equal quality", "", "audio/wav, audio/ogg", WAV, false},
+		{"wildcard means default", "", "*/*", MP3, false},
+		{"audio wildcard ranks below explicit", "", "audio/*;q=0.1, audio/ogg", OggOpus, false},
+		{"refused format", "", "audio/ogg;q=0, audio/wav;q=0.2", WAV, false},
+		{"only unknown types", "", "text/html, audio/flac", MP3, false},
+		{"bad quality counts as one", "", "audio/wav;q=abc", WAV, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Negotiate(tc.query, tc.accept, MP3)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Negotiate = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
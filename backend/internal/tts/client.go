@@ -0,0 +1,164 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"voicebook/internal/metrics"
+	"voicebook/internal/normalize"
+	"voicebook/internal/ssml"
+	"voicebook/internal/storage"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+var (
+	// ErrUnavailable — TTS-сервис не ответил
+	ErrUnavailable = errors.New("tts service unavailable")
+	// ErrNotCached — Lookup не нашёл аудио в кеше
+	ErrNotCached = errors.New("audio is not synthesized yet")
+)
+
+type Client struct {
+	baseURL string
+	ssml    bool
+	http    *http.Client
+}
+
+type Result struct {
+	Source  string `json:"source"`
+	FileURL string `json:"file_url"`
+	// MetaURL — JSON с длительностью и временем сегментов страницы (timing.Meta)
+	MetaURL string `json:"meta_url"`
+}
+
+// New создаёт клиента TTS-сервиса; supportsSSML включает отправку разметки SSML вместо текста
+func New(baseURL string, supportsSSML bool) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		ssml:    supportsSSML,
+		// транспорт otelhttp добавляет спан запроса и заголовок traceparent
+		http: &http.Client{
+			Timeout:   60 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+func (c *Client) SupportsSSML() bool {
+	return c.ssml
+}
+
+// Ping проверяет, что TTS-сервис отвечает
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v1/ping", nil)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	metrics.ObserveTTS("ping", start, err)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("tts returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Synthesize озвучивает текст страницы и возвращает ссылку на аудио в S3.
+// Каждый заголовок и предложение синтезируются отдельно, чтобы TTS-сервис вернул
+// их время в метаданных (см. timing.Sentences); паузы между ними добавляет сервис.
+// heading — заголовок главы, которую открывает страница (см. ssml.Segments).
+func (c *Client) Synthesize(ctx context.Context, text, heading string, vs storage.VoiceSettings) (res Result, err error) {
+	defer func(start time.Time) {
+		metrics.ObserveTTS("synthesize", start, err)
+		if err == nil {
+			metrics.AudioCache("tts", res.Source == "cached")
+		}
+	}(time.Now())
+
+	return c.request(ctx, c.payload(text, heading, vs, false))
+}
+
+// Lookup возвращает аудио страницы из кеша TTS-сервиса, ничего не синтезируя.
+// ErrNotCached — страница с такими настройками ещё не озвучивалась.
+func (c *Client) Lookup(ctx context.Context, text, heading string, vs storage.VoiceSettings) (res Result, err error) {
+	defer func(start time.Time) {
+		// промах кеша — обычный ответ, а не сбой сервиса
+		if errors.Is(err, ErrNotCached) {
+			metrics.ObserveTTS("lookup", start, nil)
+			return
+		}
+		metrics.ObserveTTS("lookup", start, err)
+	}(time.Now())
+
+	return c.request(ctx, c.payload(text, heading, vs, true))
+}
+
+// payload собирает запрос к TTS-сервису; от всех полей, кроме cache_only, зависит ключ кеша
+func (c *Client) payload(text, heading string, vs storage.VoiceSettings, cacheOnly bool) map[string]any {
+	lang := normalize.Detect(text)
+	segs := ssml.Segments(text, heading)
+
+	segments := make([]string, len(segs))
+	pauses := make([]int, len(segs))
+	for i, seg := range segs {
+		seg.Text = normalize.TextLang(seg.Text, lang)
+		segments[i] = seg.Text
+		if c.ssml {
+			segments[i] = ssml.Build(seg)
+		}
+		pauses[i] = seg.PauseMs
+	}
+
+	return map[string]any{
+		"text":        strings.Join(segments, "\n"),
+		"segments":    segments,
+		"pauses_ms":   pauses,
+		"voice":       vs.Voice,
+		"role":        vs.Role,
+		"speed":       vs.Speed,
+		"pitch_shift": vs.PitchShift,
+		"format":      vs.Format,
+		"ssml":        c.ssml,
+		"cache_only":  cacheOnly,
+	}
+}
+
+func (c *Client) request(ctx context.Context, payload map[string]any) (Result, error) {
+	body, _ := json.Marshal(payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/tts", bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Result{}, ErrNotCached
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("tts returned status %d", resp.StatusCode)
+	}
+
+	var res Result
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Result{}, fmt.Errorf("invalid tts response: %w", err)
+	}
+	return res, nil
+}
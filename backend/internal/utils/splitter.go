@@ -2,6 +2,7 @@ package utils
 
 import (
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -55,3 +56,59 @@ func cutIndex(text string, limit int) int {
 	}
 	return len(text)
 }
+
+// SentenceBounds делит строку на предложения: конец предложения (.!?…), за которым
+// идут пробел и заглавная буква, цифра, кавычка или тире. Возвращает границы
+// предложений в рунах без пробелов по краям; конец не включается.
+func SentenceBounds(line []rune) [][2]int {
+	var bounds [][2]int
+	start := -1
+	for i := 0; i < len(line); i++ {
+		if start < 0 {
+			if !unicode.IsSpace(line[i]) {
+				start = i
+			}
+			continue
+		}
+		if !isSentenceEnd(line[i]) {
+			continue
+		}
+		j := i + 1
+		for j < len(line) && (isSentenceEnd(line[j]) || isClosingQuote(line[j])) {
+			j++
+		}
+		if j < len(line) && !unicode.IsSpace(line[j]) {
+			i = j - 1
+			continue
+		}
+		k := j
+		for k < len(line) && unicode.IsSpace(line[k]) {
+			k++
+		}
+		if k == len(line) || startsSentence(line[k]) {
+			bounds = append(bounds, [2]int{start, j})
+			start = -1
+		}
+		i = k - 1
+	}
+	if start >= 0 {
+		end := len(line)
+		for end > start && unicode.IsSpace(line[end-1]) {
+			end--
+		}
+		bounds = append(bounds, [2]int{start, end})
+	}
+	return bounds
+}
+
+func isSentenceEnd(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+func isClosingQuote(r rune) bool {
+	return r == '"' || r == '»' || r == '”' || r == ')'
+}
+
+func startsSentence(r rune) bool {
+	return unicode.IsUpper(r) || unicode.IsDigit(r) || r == '"' || r == '«' || r == '“' || r == '—' || r == '–'
+}
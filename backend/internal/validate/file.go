@@ -0,0 +1,50 @@
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// сигнатуры форматов, которые часто присылают вместо текста; книга сейчас
+// принимается только как обычный текст в UTF-8
+var knownBinary = []struct {
+	name  string
+	magic []byte
+}{
+	{"pdf", []byte("%PDF-")},
+	{"zip/epub/docx", []byte("PK\x03\x04")},
+	{"fb2.zip", []byte("PK\x05\x06")},
+	{"doc", []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")},
+	{"rtf", []byte(`{\rtf`)},
+	{"png", []byte("\x89PNG\r\n\x1a\n")},
+	{"jpeg", []byte("\xFF\xD8\xFF")},
+	{"gif", []byte("GIF8")},
+	{"gzip", []byte("\x1F\x8B")},
+	{"mobi", []byte("BOOKMOBI")},
+}
+
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// BookFile проверяет загружаемый файл книги по содержимому, а не по расширению
+func BookFile(data []byte, maxSize int64) string {
+	if len(data) == 0 {
+		return "is empty"
+	}
+	if int64(len(data)) > maxSize {
+		return fmt.Sprintf("must be at most %d bytes", maxSize)
+	}
+	for _, b := range knownBinary {
+		if bytes.HasPrefix(data, b.magic) {
+			return "unsupported file type " + b.name + ", only plain UTF-8 text is accepted"
+		}
+	}
+	text := bytes.TrimPrefix(data, utf8BOM)
+	if bytes.IndexByte(text, 0) >= 0 || !utf8.Valid(text) {
+		return "must be plain UTF-8 text"
+	}
+	if len(bytes.TrimSpace(text)) == 0 {
+		return "is empty"
+	}
+	return ""
+}
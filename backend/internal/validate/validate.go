@@ -0,0 +1,102 @@
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	LoginMinLen    = 3
+	LoginMaxLen    = 32
+	PasswordMinLen = 8
+	PasswordMaxLen = 128
+	TitleMaxLen    = 200
+	AuthorMaxLen   = 200
+	EmailMaxLen    = 254
+)
+
+// FieldError — ошибка одного поля запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors накапливает ошибки всех полей, чтобы клиент получил их разом
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, f := range e {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Check добавляет ошибку поля, если правило вернуло непустое сообщение
+func (e *Errors) Check(field, msg string) {
+	if msg != "" {
+		*e = append(*e, FieldError{Field: field, Message: msg})
+	}
+}
+
+var loginRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+func Login(s string) string {
+	if n := utf8.RuneCountInString(s); n < LoginMinLen || n > LoginMaxLen {
+		return fmt.Sprintf("must be %d to %d characters long", LoginMinLen, LoginMaxLen)
+	}
+	if !loginRe.MatchString(s) {
+		return "may contain only latin letters, digits, '_', '.' and '-' and must start with a letter or digit"
+	}
+	return ""
+}
+
+// Email принимает только голый адрес, без имени и угловых скобок
+func Email(s string) string {
+	if len(s) > EmailMaxLen {
+		return fmt.Sprintf("must be at most %d characters long", EmailMaxLen)
+	}
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s || addr.Name != "" {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+func Password(s string) string {
+	if n := utf8.RuneCountInString(s); n < PasswordMinLen || n > PasswordMaxLen {
+		return fmt.Sprintf("must be %d to %d characters long", PasswordMinLen, PasswordMaxLen)
+	}
+	var letter, digit bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return "must contain at least one letter and one digit"
+	}
+	return ""
+}
+
+// Text проверяет строковое поле формы: обязательность и длину в символах
+func Text(s string, required bool, max int) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		if required {
+			return "is required"
+		}
+		return ""
+	}
+	if utf8.RuneCountInString(s) > max {
+		return fmt.Sprintf("must be at most %d characters long", max)
+	}
+	return ""
+}
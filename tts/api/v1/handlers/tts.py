@@ -1,26 +1,36 @@
+from fastapi import HTTPException
+
 from schemas.schemas import TtsParams
 from services.speechkit import synthesize
-from services.s3 import check_audio_exists, upload_audio
+from services.s3 import check_audio_exists, upload_audio, upload_meta, extension_by_format
 import hashlib
 
-def generate_cache_key(text: str, voice: str = "ermil", role: str = "neutral") -> str:
-    content = f"{text}:{voice}:{role}"
+def generate_cache_key(params: TtsParams) -> str:
+    content = f"{params.text}:{params.voice}:{params.role}:{params.speed}:{params.pitch_shift}:{params.format}:{params.ssml}"
+    if params.segments:
+        content += ":" + "\x1f".join(params.segments)
+    if params.pauses_ms:
+        content += ":" + ",".join(str(p) for p in params.pauses_ms)
     return hashlib.sha256(content.encode('utf-8')).hexdigest()
 
-def s3_path_by_name(name: str) -> str:
-    return f"https://storage.yandexcloud.net/listen-s3/audio/{name}.wav"
+def s3_path_by_name(name: str, extension: str) -> str:
+    return f"https://storage.yandexcloud.net/listen-s3/audio/{name}.{extension}"
 
 def tts(text: TtsParams):
-    cache_key = generate_cache_key(text.text)
-    audio_exists = check_audio_exists(cache_key)
+    cache_key = generate_cache_key(text)
+    audio_exists = check_audio_exists(cache_key, text.format)
 
-    file_url = s3_path_by_name(cache_key)
+    file_url = s3_path_by_name(cache_key, extension_by_format(text.format))
+    meta_url = s3_path_by_name(cache_key, "json")
 
     if audio_exists:
-        return {"source": "cached", "file_url": file_url}
-    
-    result = synthesize(text.text)
+        return {"source": "cached", "file_url": file_url, "meta_url": meta_url}
+    if text.cache_only:
+        raise HTTPException(status_code=404, detail="audio is not synthesized yet")
+
+    result, meta = synthesize(text)
 
-    upload_audio(cache_key, result)
+    upload_meta(cache_key, meta)
+    upload_audio(cache_key, result, text.format)
 
-    return {"source": "on_fly", "file_url": file_url}
\ No newline at end of file
+    return {"source": "on_fly", "file_url": file_url, "meta_url": meta_url}
@@ -1,4 +1,20 @@
+from typing import Literal
+
 from pydantic import BaseModel
 
 class TtsParams(BaseModel):
     text: str
+    voice: str = "ermil"
+    role: str = "neutral"
+    speed: float = 1.0
+    pitch_shift: int = 0
+    format: Literal["wav", "mp3", "oggopus"] = "wav"
+    ssml: bool = False
+    # Предложения страницы по порядку: каждое синтезируется отдельно,
+    # чтобы знать точное время его начала и конца в итоговом аудио
+    segments: list[str] | None = None
+    # Тишина после каждого сегмента, мс: после заголовков, в конце абзацев,
+    # на месте разделителей сцен. В время сегмента не входит
+    pauses_ms: list[int] | None = None
+    # Только вернуть аудио из кеша; если его нет — 404, без синтеза
+    cache_only: bool = False
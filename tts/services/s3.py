@@ -1,14 +1,22 @@
+import json
+
 import boto3
 from core.config import YC_S3_API_KEY, YC_S3_API_SECRET
 
 s3_client = boto3.client('s3', aws_access_key_id=YC_S3_API_KEY, aws_secret_access_key=YC_S3_API_SECRET, endpoint_url = "https://storage.yandexcloud.net")
 
-def upload_audio(file_name: str, data: bytes):
-    s3_client.put_object(Bucket='listen-s3', Key=f'audio/{file_name}.wav', Body=data)
+def extension_by_format(audio_format: str) -> str:
+    return {"wav": "wav", "mp3": "mp3", "oggopus": "ogg"}[audio_format]
+
+def upload_audio(file_name: str, data: bytes, audio_format: str = "wav"):
+    s3_client.put_object(Bucket='listen-s3', Key=f'audio/{file_name}.{extension_by_format(audio_format)}', Body=data)
+
+def upload_meta(file_name: str, meta: dict):
+    s3_client.put_object(Bucket='listen-s3', Key=f'audio/{file_name}.json', Body=json.dumps(meta).encode('utf-8'), ContentType='application/json')
 
-def check_audio_exists(file_name: str) -> bool:
+def check_audio_exists(file_name: str, audio_format: str = "wav") -> bool:
     try:
-        s3_client.head_object(Bucket='listen-s3', Key=f'audio/{file_name}.wav')
+        s3_client.head_object(Bucket='listen-s3', Key=f'audio/{file_name}.{extension_by_format(audio_format)}')
         return True
     except s3_client.exceptions.NoSuchKey:
         return False
@@ -16,4 +24,4 @@ def check_audio_exists(file_name: str) -> bool:
         if e.response['Error']['Code'] == '404':
             return False
         else:
-            raise
\ No newline at end of file
+            raise
@@ -1,3 +1,8 @@
+import io
+import urllib.parse
+import urllib.request
+
+from pydub import AudioSegment
 from speechkit import model_repository, configure_credentials, creds
 from core.config import YC_API_SECRET
 
@@ -7,15 +12,70 @@ configure_credentials(
     )
 )
 
-def synthesize(text):
+# SSML поддерживается только в API v1
+SPEECHKIT_V1_URL = "https://tts.api.cloud.yandex.net/speech/v1/tts:synthesize"
+LPCM_SAMPLE_RATE = 48000
+
+def synthesize(params):
+   """Возвращает аудио в нужном формате и метаданные с длительностью
+   и временем каждого сегмента в миллисекундах."""
+   segments = params.segments or [params.text]
+   pauses = params.pauses_ms or []
+
+   audio = AudioSegment.empty()
+   marks = []
+   for i, text in enumerate(segments):
+      start = len(audio)
+      if text.strip():
+         audio += synthesize_segment(params, text)
+      marks.append({"start_ms": start, "end_ms": len(audio)})
+      if i < len(pauses) and pauses[i] > 0:
+         audio += AudioSegment.silent(duration=pauses[i], frame_rate=LPCM_SAMPLE_RATE)
+
+   meta = {"duration_ms": len(audio), "segments": marks}
+   return export(audio, params.format), meta
+
+def synthesize_segment(params, text):
+   if params.ssml:
+      return synthesize_ssml(params, text)
+
    model = model_repository.synthesis_model()
 
    # Задайте настройки синтеза.
-   model.voice = 'ermil'
-   model.role = 'neutral'
-   model.pitchShift = 0
-   model.speed = 1.0
-
-   result = model.synthesize(text, raw_format=True)
-   
-   return result
+   model.voice = params.voice
+   model.role = params.role
+   model.pitchShift = params.pitch_shift
+   model.speed = params.speed
+
+   return model.synthesize(text, raw_format=False)
+
+def synthesize_ssml(params, text):
+   fields = {
+      "ssml": text,
+      "voice": params.voice,
+      "emotion": params.role,
+      "speed": params.speed,
+      "format": "lpcm",
+      "sampleRateHertz": LPCM_SAMPLE_RATE,
+   }
+   # высота голоса в Гц, как pitchShift в режиме без SSML
+   if params.pitch_shift:
+      fields["pitchShift"] = params.pitch_shift
+   data = urllib.parse.urlencode(fields).encode("utf-8")
+   req = urllib.request.Request(
+      SPEECHKIT_V1_URL,
+      data=data,
+      headers={"Authorization": f"Api-Key {YC_API_SECRET}"},
+   )
+   with urllib.request.urlopen(req, timeout=15) as resp:
+      audio = resp.read()
+
+   return AudioSegment(audio, sample_width=2, frame_rate=LPCM_SAMPLE_RATE, channels=1)
+
+def export(audio, audio_format):
+   buf = io.BytesIO()
+   if audio_format == "oggopus":
+      audio.export(buf, format="ogg", codec="libopus")
+   else:
+      audio.export(buf, format=audio_format)
+   return buf.getvalue()